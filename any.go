@@ -0,0 +1,206 @@
+package teff
+
+import (
+	"h12.io/teff/core"
+	"net"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	intPattern     = regexp.MustCompile(`^[+-]?(0|[1-9][0-9]*)$`)
+	floatPattern   = regexp.MustCompile(`^[+-]?((0|[1-9][0-9]*)\.[0-9]*([eE][+-]?(0|[1-9][0-9]*))?|(0|[1-9][0-9]*)[eE][+-]?(0|[1-9][0-9]*)|\.(0|[1-9][0-9]*)([eE][+-]?(0|[1-9][0-9]*))?)$`)
+	complexPattern = regexp.MustCompile(`^[+-]?((0|[1-9][0-9]*)(\.[0-9]*)?([eE][+-]?(0|[1-9][0-9]*))?|\.(0|[1-9][0-9]*)([eE][+-]?(0|[1-9][0-9]*))?)[+-]((0|[1-9][0-9]*)(\.[0-9]*)?([eE][+-]?(0|[1-9][0-9]*))?|\.(0|[1-9][0-9]*)([eE][+-]?(0|[1-9][0-9]*))?)i$`)
+	expZeros       = regexp.MustCompile(`([eE][+-]?)0+([0-9])`)
+)
+
+// anyCodec sets the unmarshal functions of an empty interface type t, which
+// decode a list or a node into a map[string]interface{}, an []interface{} or
+// a scalar. The type of a value is told by the type label of its node, or of
+// the key or the element holding it, and is otherwise inferred from the value.
+func (c *codec) anyCodec(t reflect.Type) {
+	c.unmarshalList = func(d *decodeState, list core.List, v reflect.Value) {
+		setAny(v, t, anyList(d, list, d.label))
+	}
+	c.unmarshalNode = func(d *decodeState, node core.Node, v reflect.Value) {
+		setAny(v, t, anyNode(d, &node))
+	}
+}
+
+var anyType = reflect.TypeOf((*interface{})(nil)).Elem()
+
+func setAny(v reflect.Value, t reflect.Type, x interface{}) {
+	if x == nil {
+		v.Set(reflect.Zero(t))
+		return
+	}
+	v.Set(reflect.ValueOf(x))
+}
+
+func anyList(d *decodeState, list core.List, label string) interface{} {
+	switch label {
+	case "map", "object":
+		return anyMap(d, list)
+	case "list", "array":
+		return anyElems(d, list)
+	case "":
+		if len(list) > 0 && isKey(list[0]) {
+			return anyMap(d, list)
+		} else if len(list) == 1 {
+			return anyNode(d, &list[0])
+		}
+		return anyElems(d, list)
+	}
+	if len(list) != 1 {
+		d.errorf(nil, anyType, "expect a single value but got %d nodes", len(list))
+		return nil
+	}
+	return anyScalar(d, &list[0], label)
+}
+
+func anyMap(d *decodeState, list core.List) map[string]interface{} {
+	m := make(map[string]interface{}, len(list))
+	path, line := d.path, d.line
+	defer func() { d.path, d.line = path, line }()
+	for i := range list {
+		node := &list[i]
+		if !isKey(*node) {
+			d.path, d.line = path, line
+			d.errorf(node, anyType, "expect a key")
+			continue
+		}
+		k := strings.TrimSuffix(node.Value, ":")
+		d.path, d.line = core.JoinKey(path, k), node.Line
+		key, err := ParseString(k)
+		if err != nil {
+			d.error(node, anyType, err)
+			continue
+		}
+		if _, ok := m[key]; ok && d.strict {
+			d.errorf(node, anyType, "duplicate key %q", k)
+			continue
+		}
+		m[key] = anyList(d, node.List, node.TypeLabel())
+	}
+	return m
+}
+
+func anyElems(d *decodeState, list core.List) []interface{} {
+	s := make([]interface{}, len(list))
+	path, line := d.path, d.line
+	defer func() { d.path, d.line = path, line }()
+	for i := range list {
+		d.path, d.line = core.JoinIndex(path, i), list[i].Line
+		s[i] = anyNode(d, &list[i])
+	}
+	return s
+}
+
+func anyNode(d *decodeState, node *core.Node) interface{} {
+	label := node.TypeLabel()
+	switch {
+	case node.IsReference:
+		d.errorf(node, anyType, "unexpected reference")
+		return nil
+	case node.Value == "_":
+		if label == "" {
+			label = "list"
+			if len(node.List) > 0 && isKey(node.List[0]) {
+				label = "map"
+			}
+		}
+		return anyList(d, node.List, label)
+	case isKey(*node):
+		d.errorf(node, anyType, "unexpected key")
+		return nil
+	case len(node.List) > 0:
+		d.errorf(&node.List[0], anyType, "unexpected child")
+		return nil
+	}
+	return anyScalar(d, node, label)
+}
+
+func anyScalar(d *decodeState, node *core.Node, label string) interface{} {
+	if label == "" {
+		label = inferLabel(node.Value)
+	}
+	s, err := ParseString(node.Value)
+	if err != nil {
+		d.error(node, anyType, err)
+		return nil
+	}
+	var x interface{}
+	switch label {
+	case "nil":
+		if node.Value != "nil" {
+			d.errorf(node, anyType, "expect nil")
+		}
+		return nil
+	case "list", "array", "map", "object":
+		if node.Value != "nil" {
+			d.errorf(node, anyType, "expect nil or _")
+			return nil
+		} else if label == "map" || label == "object" {
+			return map[string]interface{}(nil)
+		}
+		return []interface{}(nil)
+	case "string":
+		return s
+	case "bool":
+		x, err = strconv.ParseBool(s)
+	case "int":
+		x, err = strconv.ParseInt(s, 10, 64)
+	case "float":
+		x, err = strconv.ParseFloat(s, 64)
+	case "complex":
+		x, err = strconv.ParseComplex(s, 128)
+	case "time":
+		x, err = time.Parse(time.RFC3339Nano, s)
+	case "ip":
+		ip := net.ParseIP(s)
+		if ip == nil {
+			d.errorf(node, anyType, "invalid IP address: %s", s)
+			return nil
+		}
+		return ip
+	default:
+		d.errorf(node, anyType, "unknown type label <%s>", label)
+		return nil
+	}
+	if err != nil {
+		d.error(node, anyType, err)
+		return nil
+	}
+	return x
+}
+
+// inferLabel returns the type label of a value by the first grammar it
+// matches among nil, boolean, integer, float, complex, time and IP.
+func inferLabel(value string) string {
+	switch {
+	case strings.HasPrefix(value, `"`):
+		return "string"
+	case value == "nil":
+		return "nil"
+	case value == "true" || value == "false":
+		return "bool"
+	case intPattern.MatchString(value):
+		if _, err := strconv.ParseInt(value, 10, 64); err == nil {
+			return "int"
+		}
+		return "float"
+	case floatPattern.MatchString(value):
+		return "float"
+	case complexPattern.MatchString(value):
+		return "complex"
+	}
+	if _, err := time.Parse(time.RFC3339Nano, value); err == nil {
+		return "time"
+	} else if net.ParseIP(value) != nil {
+		return "ip"
+	}
+	return "string"
+}
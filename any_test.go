@@ -0,0 +1,152 @@
+package teff
+
+import (
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testAnyText = `name:
+	x
+port:
+	8080
+ratio:
+	0.5
+on:
+	true
+c:
+	1+2i
+at:
+	2015-01-02T03:04:05Z
+ip:
+	10.0.0.1
+quoted:
+	"12"
+n:
+	nil
+list:
+	1
+	a
+#<list>
+one:
+	1
+#<string>
+zip:
+	12345
+f:
+	#<float>
+	1
+nested:
+	_
+		k:
+			v
+	_
+		1
+		2
+#<map>
+empty:`
+
+func TestUnmarshalAny(t *testing.T) {
+	var v interface{}
+	if err := Unmarshal([]byte(testAnyText), &v); err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]interface{}{
+		"name":   "x",
+		"port":   int64(8080),
+		"ratio":  0.5,
+		"on":     true,
+		"c":      complex(1, 2),
+		"at":     time.Date(2015, 1, 2, 3, 4, 5, 0, time.UTC),
+		"ip":     net.ParseIP("10.0.0.1"),
+		"quoted": "12",
+		"n":      nil,
+		"list":   []interface{}{int64(1), "a"},
+		"one":    []interface{}{int64(1)},
+		"zip":    "12345",
+		"f":      1.0,
+		"nested": []interface{}{map[string]interface{}{"k": "v"}, []interface{}{int64(1), int64(2)}},
+		"empty":  map[string]interface{}{},
+	}
+	if !reflect.DeepEqual(v, expected) {
+		t.Fatalf("expect\n%#v\nbut got\n%#v", expected, v)
+	}
+	for i, testcase := range []struct {
+		text     string
+		expected interface{}
+	}{
+		{"12", int64(12)},
+		{"99999999999999999999", 1e20},
+		{"a\nb", []interface{}{"a", "b"}},
+		{"", []interface{}{}},
+		{"nil", nil},
+		{"_\n\t1", []interface{}{int64(1)}},
+	} {
+		var v interface{}
+		if err := Unmarshal([]byte(testcase.text), &v); err != nil {
+			t.Fatalf("testcase %d: %v", i, err)
+		}
+		if !reflect.DeepEqual(v, testcase.expected) {
+			t.Fatalf("testcase %d: expect %#v but got %#v", i, testcase.expected, v)
+		}
+	}
+}
+
+func TestMarshalAnyRoundTrip(t *testing.T) {
+	v := map[string]interface{}{
+		"s": "12",
+		"i": int64(12),
+		"f": 2.0,
+		"b": true,
+		"c": complex(1, -2.5),
+		"l": []interface{}{"true", int64(1), nil, []interface{}{"x", "y"}},
+		"m": map[string]interface{}{"k": "v"},
+		"n": nil,
+		"e": map[string]interface{}{},
+		"o": []interface{}{[]interface{}{"x"}},
+		"x": 1e-9,
+		"y": complex(0, 1e-7),
+		"z": []interface{}{nil, []interface{}(nil), map[string]interface{}(nil)},
+		"u": []interface{}{nil},
+		"v": []interface{}(nil),
+		"w": map[string]interface{}(nil),
+	}
+	data, err := Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var actual interface{}
+	if err := UnmarshalStrict(data, &actual); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(actual, v) {
+		t.Fatalf("expect\n%#v\nbut got\n%#v\nfrom\n%s", v, actual, data)
+	}
+}
+
+func TestUnmarshalAnyError(t *testing.T) {
+	for i, testcase := range []struct {
+		text string
+		err  string
+	}{
+		{"#<int>\nx:\n\ta", `unmarshal: line 3: ^x: cannot decode "a" into interface {}: strconv.ParseInt: parsing "a": invalid syntax`},
+		{"x:\n\t#<foo>\n\t1", `unmarshal: line 3: ^x: cannot decode "1" into interface {}: unknown type label <foo>`},
+		{"#<int>\nx:\n\t1\n\t2", `unmarshal: line 2: ^x: cannot decode into interface {}: expect a single value but got 2 nodes`},
+		{"x:\n\t1\ny", `unmarshal: line 3: ^: cannot decode "y" into interface {}: expect a key`},
+		{"_\n\ta\n\t\tb", `unmarshal: line 3: ^[0]: cannot decode "b" into interface {}: unexpected child`},
+		{"x:\n\t#<list>\n\t1", `unmarshal: line 3: ^x: cannot decode "1" into interface {}: expect nil or _`},
+	} {
+		var v interface{}
+		err := Unmarshal([]byte(testcase.text), &v)
+		if err == nil || err.Error() != testcase.err {
+			t.Fatalf("testcase %d: expect error %q but got %v", i, testcase.err, err)
+		}
+	}
+	var v interface{}
+	err := UnmarshalStrict([]byte("x:\n\t1\nx:\n\t2"), &v)
+	if err == nil || !strings.Contains(err.Error(), `duplicate key "x"`) {
+		t.Fatalf("expect duplicate key error but got %v", err)
+	}
+}
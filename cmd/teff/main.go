@@ -0,0 +1,96 @@
+// Command teff works with TEFF documents.
+//
+// Usage:
+//
+//	teff convert --from json|yaml|teff --to json|yaml|teff [file]
+//
+// convert reads a document from file or the standard input and writes the
+// converted document to the standard output.
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"h12.io/teff/core"
+	"h12.io/teff/jsonteff"
+	"h12.io/teff/yamlteff"
+	"io"
+	"os"
+)
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+	}
+	var err error
+	switch os.Args[1] {
+	case "convert":
+		err = convert(os.Args[2:])
+	default:
+		usage()
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "teff:", err)
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: teff convert --from json|yaml|teff --to json|yaml|teff [file]")
+	os.Exit(2)
+}
+
+func convert(args []string) error {
+	fs := flag.NewFlagSet("convert", flag.ExitOnError)
+	from := fs.String("from", "json", "input format: json, yaml or teff")
+	to := fs.String("to", "teff", "output format: json, yaml or teff")
+	fs.Parse(args)
+
+	var r io.Reader = os.Stdin
+	if fs.NArg() > 0 {
+		f, err := os.Open(fs.Arg(0))
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r = f
+	}
+
+	list, err := readList(r, *from)
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	if err := writeList(&buf, list, *to); err != nil {
+		return err
+	}
+	buf.WriteByte('\n')
+	_, err = os.Stdout.Write(buf.Bytes())
+	return err
+}
+
+func readList(r io.Reader, format string) (core.List, error) {
+	switch format {
+	case "json":
+		return jsonteff.FromJSON(r)
+	case "yaml":
+		return yamlteff.FromYAML(r)
+	case "teff":
+		return core.Parse(r)
+	}
+	return nil, errors.New("unknown input format " + format)
+}
+
+func writeList(w io.Writer, list core.List, format string) error {
+	switch format {
+	case "json":
+		return jsonteff.ToJSON(w, list)
+	case "yaml":
+		return yamlteff.ToYAML(w, list)
+	case "teff":
+		return list.Marshal(w, "", "\t")
+	}
+	return errors.New("unknown output format " + format)
+}
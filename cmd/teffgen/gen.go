@@ -0,0 +1,548 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type kind int
+
+const (
+	otherKind kind = iota
+	basicKind
+	structKind
+	ptrKind
+	sliceKind
+)
+
+// fieldType is the type of a field as far as the generated code is concerned.
+type fieldType struct {
+	kind  kind
+	name  string // the type as written in Go
+	basic string // the underlying type of a basicKind
+	elem  *fieldType
+}
+
+type field struct {
+	name      string
+	key       string
+	omitEmpty bool
+	redact    bool
+	typ       *fieldType
+}
+
+var basicTypes = map[string]string{
+	"bool": "bool", "string": "string",
+	"int": "int", "int8": "int8", "int16": "int16", "int32": "int32", "int64": "int64", "rune": "int32",
+	"uint": "uint", "uint8": "uint8", "uint16": "uint16", "uint32": "uint32", "uint64": "uint64", "uintptr": "uintptr", "byte": "uint8",
+	"float32": "float32", "float64": "float64",
+}
+
+type generator struct {
+	specs      map[string]*ast.TypeSpec
+	structs    map[string]bool
+	marshalers map[string]bool
+	imports    map[string]bool
+	buf        bytes.Buffer
+}
+
+// generate returns the source of the methods of the struct types names
+// declared in the package in dir.
+func generate(dir string, names []string) ([]byte, error) {
+	fset := token.NewFileSet()
+	pkgs, err := parser.ParseDir(fset, dir, func(fi os.FileInfo) bool {
+		return !strings.HasSuffix(fi.Name(), "_test.go") && !strings.HasSuffix(fi.Name(), "_teff.go")
+	}, 0)
+	if err != nil {
+		return nil, err
+	}
+	if len(pkgs) != 1 {
+		return nil, fmt.Errorf("expect one package in %s but got %d", dir, len(pkgs))
+	}
+	g := &generator{
+		specs:      make(map[string]*ast.TypeSpec),
+		structs:    make(map[string]bool),
+		marshalers: make(map[string]bool),
+		imports:    map[string]bool{"h12.io/teff": true, "h12.io/teff/core": true},
+	}
+	var pkgName string
+	var files []*ast.File
+	for name, pkg := range pkgs {
+		pkgName = name
+		for _, file := range pkg.Files {
+			files = append(files, file)
+		}
+	}
+	for _, file := range files {
+		g.collect(file)
+	}
+	for _, name := range names {
+		spec, ok := g.specs[name]
+		if !ok {
+			return nil, fmt.Errorf("type %s not found", name)
+		}
+		if _, ok := spec.Type.(*ast.StructType); !ok {
+			return nil, fmt.Errorf("type %s is not a struct", name)
+		}
+		g.structs[name] = true
+	}
+	for _, name := range names {
+		fields, err := g.fields(g.specs[name].Type.(*ast.StructType))
+		if err != nil {
+			return nil, fmt.Errorf("type %s: %v", name, err)
+		}
+		g.marshalMethod(name, fields)
+		g.unmarshalMethod(name, fields)
+	}
+	var src bytes.Buffer
+	fmt.Fprintf(&src, "// Code generated by teffgen; DO NOT EDIT.\n\npackage %s\n\nimport (\n", pkgName)
+	var imports []string
+	for path := range g.imports {
+		imports = append(imports, path)
+	}
+	sort.Strings(imports)
+	for _, path := range imports {
+		fmt.Fprintf(&src, "%q\n", path)
+	}
+	src.WriteString(")\n")
+	src.WriteString(stateTypes)
+	src.Write(g.buf.Bytes())
+	return format.Source(src.Bytes())
+}
+
+// collect collects the type declarations and the types that have their own
+// Marshaler or Unmarshaler methods.
+func (g *generator) collect(file *ast.File) {
+	for _, decl := range file.Decls {
+		switch decl := decl.(type) {
+		case *ast.GenDecl:
+			for _, spec := range decl.Specs {
+				if spec, ok := spec.(*ast.TypeSpec); ok {
+					g.specs[spec.Name.Name] = spec
+				}
+			}
+		case *ast.FuncDecl:
+			if decl.Recv == nil || len(decl.Recv.List) != 1 {
+				continue
+			}
+			if name := decl.Name.Name; name != "MarshalTEFF" && name != "UnmarshalTEFF" {
+				continue
+			}
+			recv := decl.Recv.List[0].Type
+			if star, ok := recv.(*ast.StarExpr); ok {
+				recv = star.X
+			}
+			if ident, ok := recv.(*ast.Ident); ok {
+				g.marshalers[ident.Name] = true
+			}
+		}
+	}
+}
+
+// fields returns the encoded fields of a struct following the rules of
+// teff.StructFields.
+func (g *generator) fields(st *ast.StructType) ([]field, error) {
+	var fields []field
+	for _, f := range st.Fields.List {
+		var names []string
+		for _, name := range f.Names {
+			names = append(names, name.Name)
+		}
+		if len(f.Names) == 0 {
+			names = append(names, embeddedName(f.Type))
+		}
+		var tag string
+		if f.Tag != nil {
+			s, err := strconv.Unquote(f.Tag.Value)
+			if err != nil {
+				return nil, err
+			}
+			tag = reflect.StructTag(s).Get("teff")
+		}
+		if tag == "-" {
+			continue
+		}
+		key, opts := tag, ""
+		if i := strings.IndexByte(tag, ','); i >= 0 {
+			key, opts = tag[:i], tag[i+1:]
+		}
+		omitEmpty, redact := false, false
+		for _, opt := range strings.Split(opts, ",") {
+			switch opt {
+			case "omitempty":
+				omitEmpty = true
+			case "redact":
+				redact = true
+			}
+		}
+		for _, name := range names {
+			if !ast.IsExported(name) {
+				continue
+			}
+			fd := field{name: name, key: key, omitEmpty: omitEmpty, redact: redact, typ: g.resolve(f.Type)}
+			if fd.key == "" {
+				fd.key = name
+			}
+			fields = append(fields, fd)
+		}
+	}
+	return fields, nil
+}
+
+func embeddedName(expr ast.Expr) string {
+	switch expr := expr.(type) {
+	case *ast.StarExpr:
+		return embeddedName(expr.X)
+	case *ast.SelectorExpr:
+		return expr.Sel.Name
+	case *ast.Ident:
+		return expr.Name
+	}
+	return ""
+}
+
+// resolve returns the type of a field. A slice is encoded by the generated
+// code only when its element is a basic or listed struct type, and a pointer
+// is always encoded by the encoder, which keeps track of the shared ones.
+func (g *generator) resolve(expr ast.Expr) *fieldType {
+	switch expr := expr.(type) {
+	case *ast.Ident:
+		if basic, ok := g.basic(expr.Name, 0); ok {
+			return &fieldType{kind: basicKind, name: expr.Name, basic: basic}
+		}
+		if g.structs[expr.Name] {
+			return &fieldType{kind: structKind, name: expr.Name}
+		}
+	case *ast.StarExpr:
+		return &fieldType{kind: ptrKind}
+	case *ast.ArrayType:
+		elem := g.resolve(expr.Elt)
+		if expr.Len == nil && (elem.kind == basicKind || elem.kind == structKind) {
+			return &fieldType{kind: sliceKind, name: "[]" + elem.name, elem: elem}
+		}
+	}
+	return &fieldType{kind: otherKind}
+}
+
+// basic returns the underlying basic type of a predeclared or local type.
+func (g *generator) basic(name string, depth int) (string, bool) {
+	if g.marshalers[name] || depth > len(g.specs) {
+		return "", false
+	}
+	if spec, ok := g.specs[name]; ok {
+		if ident, ok := spec.Type.(*ast.Ident); ok {
+			return g.basic(ident.Name, depth+1)
+		}
+		return "", false
+	}
+	basic, ok := basicTypes[name]
+	return basic, ok
+}
+
+func (g *generator) p(format string, args ...interface{}) {
+	fmt.Fprintf(&g.buf, format, args...)
+	g.buf.WriteByte('\n')
+}
+
+func (g *generator) use(path string) {
+	g.imports[path] = true
+}
+
+// stateTypes declares the state of package teff that the generated methods
+// are called with, which must be identical to the types encoder and decoder
+// of package teff.
+const stateTypes = `
+// teffEncoder and teffDecoder are the state of encoding and decoding a value
+// that package teff calls the generated methods with.
+type (
+	teffEncoder = interface {
+		CollapseNil() bool
+		MarshalKey(list core.List, key string, v interface{}) (core.List, error)
+	}
+	teffDecoder = interface {
+		Fields(list core.List, x interface{})
+		Node(node *core.Node, x interface{})
+		Scalar(list core.List, v interface{}) (*core.Node, bool)
+		Value(node *core.Node, v interface{}) bool
+		Slice(list core.List) (core.List, bool)
+		Path() string
+		Element(path string, i int, node *core.Node)
+		Error(node *core.Node, v interface{}, err error)
+		UnmarshalList(list core.List, v interface{})
+	}
+)
+`
+
+func (g *generator) marshalMethod(name string, fields []field) {
+	g.p("\n// MarshalTEFF encodes x into a list.")
+	g.p("func (x %s) MarshalTEFF() (core.List, error) {", name)
+	g.p("return teff.MarshalList(&x)")
+	g.p("}")
+	g.p("\n// MarshalTEFFState encodes x into a list with the state of the encoder.")
+	g.p("func (x *%s) MarshalTEFFState(s teffEncoder) (core.List, error) {", name)
+	g.p("list := make(core.List, 0, %d)", len(fields))
+	values := 0
+	for _, f := range fields {
+		if f.redact || f.typ.kind == basicKind {
+			values++
+		}
+	}
+	if values > 0 {
+		g.p("values := make(core.List, %d)", values)
+	}
+	for _, f := range fields {
+		if !f.redact && f.typ.returnsErr() {
+			g.p("var err error")
+			break
+		}
+	}
+	values = 0
+	for _, f := range fields {
+		value := "x." + f.name
+		cond := ""
+		if f.omitEmpty {
+			cond = g.notEmpty(f.typ, value)
+		}
+		if cond != "" {
+			g.p("if %s {", cond)
+		}
+		key := strconv.Quote(f.key + ":")
+		switch {
+		case f.redact, f.typ.kind == basicKind:
+			if f.redact {
+				g.p("values[%d].Value = teff.RedactedValue", values)
+			} else {
+				g.p("values[%d].Value = %s", values, g.format(f.typ, value))
+			}
+			g.p("list = append(list, core.Node{Value: %s, List: values[%d:%d:%[3]d]})", key, values, values+1)
+			values++
+		case f.typ.kind == structKind:
+			g.p("if l, err := %s.MarshalTEFFState(s); err != nil {", value)
+			g.p("return nil, err")
+			g.p("} else {")
+			g.p("list = append(list, core.Node{Value: %s, List: l})", key)
+			g.p("}")
+		case f.typ.kind == sliceKind:
+			g.marshalSlice(f.typ, value, key)
+		default:
+			g.p("if list, err = s.MarshalKey(list, %s, &%s); err != nil {", key, value)
+			g.p("return nil, err")
+			g.p("}")
+		}
+		if cond != "" {
+			g.p("}")
+		}
+	}
+	g.p("return list, nil")
+	g.p("}")
+}
+
+// returnsErr reports whether the encoding of t may fail.
+func (t *fieldType) returnsErr() bool {
+	switch t.kind {
+	case basicKind:
+		return false
+	case sliceKind:
+		return t.elem.returnsErr()
+	}
+	return true
+}
+
+func (g *generator) notEmpty(t *fieldType, value string) string {
+	switch t.kind {
+	case basicKind:
+		switch t.basic {
+		case "bool":
+			return value
+		case "string":
+			return value + ` != ""`
+		}
+		return value + " != 0"
+	case ptrKind:
+		return value + " != nil"
+	case sliceKind:
+		return "len(" + value + ") != 0"
+	}
+	return "!teff.IsEmpty(" + value + ")"
+}
+
+// marshalSlice emits the statements appending the key node of a slice, which
+// is nil unless the encoder collapses it.
+func (g *generator) marshalSlice(t *fieldType, value, key string) {
+	g.p("if %s == nil && !s.CollapseNil() {", value)
+	g.p(`list = append(list, core.Node{Value: %s, List: core.List{{Value: "nil"}}})`, key)
+	g.p("} else {")
+	g.p("l := make(core.List, len(%s))", value)
+	g.p("for i := range %s {", value)
+	switch t.elem.kind {
+	case basicKind:
+		g.p("l[i].Value = %s", g.format(t.elem, value+"[i]"))
+	case structKind:
+		g.p(`l[i].Value = "_"`)
+		g.p("if l[i].List, err = %s[i].MarshalTEFFState(s); err != nil {", value)
+		g.p("return nil, err")
+		g.p("}")
+	}
+	g.p("}")
+	g.p("list = append(list, core.Node{Value: %s, List: l})", key)
+	g.p("}")
+}
+
+// format returns the expression encoding value of a basic type.
+func (g *generator) format(t *fieldType, value string) string {
+	switch t.basic {
+	case "bool":
+		g.use("strconv")
+		return "strconv.FormatBool(" + convert("bool", t, value) + ")"
+	case "string":
+		return "teff.FormatString(" + convert("string", t, value) + ")"
+	case "float32", "float64":
+		g.use("strconv")
+		return "strconv.FormatFloat(" + convert("float64", t, value) + ", 'g', -1, " + t.basic[5:] + ")"
+	}
+	g.use("strconv")
+	if strings.HasPrefix(t.basic, "u") {
+		return "strconv.FormatUint(" + convert("uint64", t, value) + ", 10)"
+	}
+	return "strconv.FormatInt(" + convert("int64", t, value) + ", 10)"
+}
+
+func convert(to string, t *fieldType, value string) string {
+	if t.name == to {
+		return value
+	}
+	return to + "(" + value + ")"
+}
+
+func (g *generator) unmarshalMethod(name string, fields []field) {
+	g.p("\n// UnmarshalTEFF decodes list into x.")
+	g.p("func (x *%s) UnmarshalTEFF(list core.List) error {", name)
+	g.p("return teff.UnmarshalList(list, x)")
+	g.p("}")
+	g.p("\n// UnmarshalTEFFKey decodes the child list of the key node into the field of")
+	g.p("// x with the state of the decoder, and reports whether there is the field.")
+	g.p("func (x *%s) UnmarshalTEFFKey(d teffDecoder, node *core.Node) bool {", name)
+	if len(fields) == 0 {
+		g.p("return false")
+		g.p("}")
+		return
+	}
+	g.p("switch node.Value {")
+	seen := make(map[string]bool)
+	for _, f := range fields {
+		if seen[f.key] {
+			continue
+		}
+		seen[f.key] = true
+		g.p("case %s:", strconv.Quote(f.key+":"))
+		if f.redact {
+			g.p("if len(node.List) != 1 || node.List[0].IsReference || node.List[0].Value != teff.RedactedValue || len(node.List[0].List) != 0 {")
+		}
+		value := "x." + f.name
+		switch f.typ.kind {
+		case basicKind:
+			g.p("if n, ok := d.Scalar(node.List, &%s); ok {", value)
+			g.parse(f.typ, "n", "&"+value)
+			g.p("}")
+		case structKind:
+			g.p("d.Fields(node.List, &%s)", value)
+		case sliceKind:
+			g.unmarshalSlice(f.typ, value)
+		default:
+			g.p("d.UnmarshalList(node.List, &%s)", value)
+		}
+		if f.redact {
+			g.p("}")
+		}
+	}
+	g.p("default:")
+	g.p("return false")
+	g.p("}")
+	g.p("return true")
+	g.p("}")
+}
+
+// unmarshalSlice emits the statements decoding node.List into a slice, which
+// appends the elements to it as the decoder does.
+func (g *generator) unmarshalSlice(t *fieldType, value string) {
+	g.p("if l, ok := d.Slice(node.List); !ok {")
+	g.p("%s = nil", value)
+	g.p("} else {")
+	g.p("if %s == nil {", value)
+	g.p("%s = make(%s, 0, len(l))", value, t.name)
+	g.p("}")
+	g.p("path := d.Path()")
+	g.p("for i := range l {")
+	g.p("d.Element(path, i, &l[i])")
+	g.p("%s = append(%[1]s, %s)", value, zero(t.elem))
+	g.p("e := &%s[len(%[1]s)-1]", value)
+	switch t.elem.kind {
+	case basicKind:
+		g.p("if n := &l[i]; d.Value(n, e) {")
+		g.parse(t.elem, "n", "e")
+		g.p("}")
+	case structKind:
+		g.p("d.Node(&l[i], e)")
+	}
+	g.p("}")
+	g.p("}")
+}
+
+// zero returns the zero value of a basic or struct type.
+func zero(t *fieldType) string {
+	switch {
+	case t.kind == structKind:
+		return t.name + "{}"
+	case t.basic == "string":
+		return `""`
+	case t.basic == "bool":
+		return "false"
+	}
+	return "0"
+}
+
+// parse emits the statements decoding the value of node into the value of a
+// basic type pointed to by ptr, which record the error of an invalid value.
+func (g *generator) parse(t *fieldType, node, ptr string) {
+	value := node + ".Value"
+	var call, result string
+	switch t.basic {
+	case "string":
+		call, result = "teff.ParseString("+value+")", "string"
+	case "bool":
+		g.use("strconv")
+		call, result = "strconv.ParseBool("+value+")", "bool"
+	case "float32", "float64":
+		g.use("strconv")
+		call, result = "strconv.ParseFloat("+value+", "+t.basic[5:]+")", "float64"
+	default:
+		g.use("strconv")
+		bits := strings.TrimLeft(t.basic, "uint")
+		if bits == "" || bits == "ptr" {
+			bits = "0"
+		}
+		if strings.HasPrefix(t.basic, "u") {
+			call, result = "strconv.ParseUint("+value+", 10, "+bits+")", "uint64"
+		} else {
+			call, result = "strconv.ParseInt("+value+", 10, "+bits+")", "int64"
+		}
+	}
+	g.p("if v, err := %s; err != nil {", call)
+	g.p("d.Error(%s, %s, err)", node, ptr)
+	g.p("} else {")
+	dst := "*" + ptr
+	if strings.HasPrefix(ptr, "&") {
+		dst = ptr[1:]
+	}
+	g.p("%s = %s", dst, convert(t.name, &fieldType{name: result}, "v"))
+	g.p("}")
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+)
+
+func TestGenerate(t *testing.T) {
+	src, err := generate("internal/fixture", []string{"Config", "Owner"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected, err := ioutil.ReadFile("internal/fixture/config_teff.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(src, expected) {
+		t.Fatalf("expect\n%s\nbut got\n%s", expected, src)
+	}
+}
+
+func TestGenerateError(t *testing.T) {
+	for i, testcase := range [][]string{
+		{"Missing"},
+		{"Celsius"},
+	} {
+		if _, err := generate("internal/fixture", testcase); err == nil {
+			t.Fatalf("testcase %d: expect error but got nil", i)
+		}
+	}
+}
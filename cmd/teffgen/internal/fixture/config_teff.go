@@ -0,0 +1,275 @@
+// Code generated by teffgen; DO NOT EDIT.
+
+package fixture
+
+import (
+	"h12.io/teff"
+	"h12.io/teff/core"
+	"strconv"
+)
+
+// teffEncoder and teffDecoder are the state of encoding and decoding a value
+// that package teff calls the generated methods with.
+type (
+	teffEncoder = interface {
+		CollapseNil() bool
+		MarshalKey(list core.List, key string, v interface{}) (core.List, error)
+	}
+	teffDecoder = interface {
+		Fields(list core.List, x interface{})
+		Node(node *core.Node, x interface{})
+		Scalar(list core.List, v interface{}) (*core.Node, bool)
+		Value(node *core.Node, v interface{}) bool
+		Slice(list core.List) (core.List, bool)
+		Path() string
+		Element(path string, i int, node *core.Node)
+		Error(node *core.Node, v interface{}, err error)
+		UnmarshalList(list core.List, v interface{})
+	}
+)
+
+// MarshalTEFF encodes x into a list.
+func (x Config) MarshalTEFF() (core.List, error) {
+	return teff.MarshalList(&x)
+}
+
+// MarshalTEFFState encodes x into a list with the state of the encoder.
+func (x *Config) MarshalTEFFState(s teffEncoder) (core.List, error) {
+	list := make(core.List, 0, 13)
+	values := make(core.List, 6)
+	var err error
+	values[0].Value = teff.FormatString(x.Name)
+	list = append(list, core.Node{Value: "name:", List: values[0:1:1]})
+	values[1].Value = strconv.FormatUint(uint64(x.Port), 10)
+	list = append(list, core.Node{Value: "Port:", List: values[1:2:2]})
+	values[2].Value = strconv.FormatFloat(x.Ratio, 'g', -1, 64)
+	list = append(list, core.Node{Value: "Ratio:", List: values[2:3:3]})
+	if x.Debug {
+		values[3].Value = strconv.FormatBool(x.Debug)
+		list = append(list, core.Node{Value: "Debug:", List: values[3:4:4]})
+	}
+	values[4].Value = strconv.FormatFloat(float64(x.Limit), 'g', -1, 64)
+	list = append(list, core.Node{Value: "Limit:", List: values[4:5:5]})
+	if list, err = s.MarshalKey(list, "Retries:", &x.Retries); err != nil {
+		return nil, err
+	}
+	if x.Tags == nil && !s.CollapseNil() {
+		list = append(list, core.Node{Value: "Tags:", List: core.List{{Value: "nil"}}})
+	} else {
+		l := make(core.List, len(x.Tags))
+		for i := range x.Tags {
+			l[i].Value = teff.FormatString(x.Tags[i])
+		}
+		list = append(list, core.Node{Value: "Tags:", List: l})
+	}
+	if len(x.Weights) != 0 {
+		if x.Weights == nil && !s.CollapseNil() {
+			list = append(list, core.Node{Value: "weights:", List: core.List{{Value: "nil"}}})
+		} else {
+			l := make(core.List, len(x.Weights))
+			for i := range x.Weights {
+				l[i].Value = strconv.FormatFloat(float64(x.Weights[i]), 'g', -1, 32)
+			}
+			list = append(list, core.Node{Value: "weights:", List: l})
+		}
+	}
+	if list, err = s.MarshalKey(list, "Hosts:", &x.Hosts); err != nil {
+		return nil, err
+	}
+	if x.Owner != nil {
+		if list, err = s.MarshalKey(list, "Owner:", &x.Owner); err != nil {
+			return nil, err
+		}
+	}
+	if x.Admins == nil && !s.CollapseNil() {
+		list = append(list, core.Node{Value: "Admins:", List: core.List{{Value: "nil"}}})
+	} else {
+		l := make(core.List, len(x.Admins))
+		for i := range x.Admins {
+			l[i].Value = "_"
+			if l[i].List, err = x.Admins[i].MarshalTEFFState(s); err != nil {
+				return nil, err
+			}
+		}
+		list = append(list, core.Node{Value: "Admins:", List: l})
+	}
+	if list, err = s.MarshalKey(list, "Backups:", &x.Backups); err != nil {
+		return nil, err
+	}
+	values[5].Value = teff.RedactedValue
+	list = append(list, core.Node{Value: "Token:", List: values[5:6:6]})
+	return list, nil
+}
+
+// UnmarshalTEFF decodes list into x.
+func (x *Config) UnmarshalTEFF(list core.List) error {
+	return teff.UnmarshalList(list, x)
+}
+
+// UnmarshalTEFFKey decodes the child list of the key node into the field of
+// x with the state of the decoder, and reports whether there is the field.
+func (x *Config) UnmarshalTEFFKey(d teffDecoder, node *core.Node) bool {
+	switch node.Value {
+	case "name:":
+		if n, ok := d.Scalar(node.List, &x.Name); ok {
+			if v, err := teff.ParseString(n.Value); err != nil {
+				d.Error(n, &x.Name, err)
+			} else {
+				x.Name = v
+			}
+		}
+	case "Port:":
+		if n, ok := d.Scalar(node.List, &x.Port); ok {
+			if v, err := strconv.ParseUint(n.Value, 10, 16); err != nil {
+				d.Error(n, &x.Port, err)
+			} else {
+				x.Port = uint16(v)
+			}
+		}
+	case "Ratio:":
+		if n, ok := d.Scalar(node.List, &x.Ratio); ok {
+			if v, err := strconv.ParseFloat(n.Value, 64); err != nil {
+				d.Error(n, &x.Ratio, err)
+			} else {
+				x.Ratio = v
+			}
+		}
+	case "Debug:":
+		if n, ok := d.Scalar(node.List, &x.Debug); ok {
+			if v, err := strconv.ParseBool(n.Value); err != nil {
+				d.Error(n, &x.Debug, err)
+			} else {
+				x.Debug = v
+			}
+		}
+	case "Limit:":
+		if n, ok := d.Scalar(node.List, &x.Limit); ok {
+			if v, err := strconv.ParseFloat(n.Value, 64); err != nil {
+				d.Error(n, &x.Limit, err)
+			} else {
+				x.Limit = Celsius(v)
+			}
+		}
+	case "Retries:":
+		d.UnmarshalList(node.List, &x.Retries)
+	case "Tags:":
+		if l, ok := d.Slice(node.List); !ok {
+			x.Tags = nil
+		} else {
+			if x.Tags == nil {
+				x.Tags = make([]string, 0, len(l))
+			}
+			path := d.Path()
+			for i := range l {
+				d.Element(path, i, &l[i])
+				x.Tags = append(x.Tags, "")
+				e := &x.Tags[len(x.Tags)-1]
+				if n := &l[i]; d.Value(n, e) {
+					if v, err := teff.ParseString(n.Value); err != nil {
+						d.Error(n, e, err)
+					} else {
+						*e = v
+					}
+				}
+			}
+		}
+	case "weights:":
+		if l, ok := d.Slice(node.List); !ok {
+			x.Weights = nil
+		} else {
+			if x.Weights == nil {
+				x.Weights = make([]float32, 0, len(l))
+			}
+			path := d.Path()
+			for i := range l {
+				d.Element(path, i, &l[i])
+				x.Weights = append(x.Weights, 0)
+				e := &x.Weights[len(x.Weights)-1]
+				if n := &l[i]; d.Value(n, e) {
+					if v, err := strconv.ParseFloat(n.Value, 32); err != nil {
+						d.Error(n, e, err)
+					} else {
+						*e = float32(v)
+					}
+				}
+			}
+		}
+	case "Hosts:":
+		d.UnmarshalList(node.List, &x.Hosts)
+	case "Owner:":
+		d.UnmarshalList(node.List, &x.Owner)
+	case "Admins:":
+		if l, ok := d.Slice(node.List); !ok {
+			x.Admins = nil
+		} else {
+			if x.Admins == nil {
+				x.Admins = make([]Owner, 0, len(l))
+			}
+			path := d.Path()
+			for i := range l {
+				d.Element(path, i, &l[i])
+				x.Admins = append(x.Admins, Owner{})
+				e := &x.Admins[len(x.Admins)-1]
+				d.Node(&l[i], e)
+			}
+		}
+	case "Backups:":
+		d.UnmarshalList(node.List, &x.Backups)
+	case "Token:":
+		if len(node.List) != 1 || node.List[0].IsReference || node.List[0].Value != teff.RedactedValue || len(node.List[0].List) != 0 {
+			if n, ok := d.Scalar(node.List, &x.Token); ok {
+				if v, err := teff.ParseString(n.Value); err != nil {
+					d.Error(n, &x.Token, err)
+				} else {
+					x.Token = v
+				}
+			}
+		}
+	default:
+		return false
+	}
+	return true
+}
+
+// MarshalTEFF encodes x into a list.
+func (x Owner) MarshalTEFF() (core.List, error) {
+	return teff.MarshalList(&x)
+}
+
+// MarshalTEFFState encodes x into a list with the state of the encoder.
+func (x *Owner) MarshalTEFFState(s teffEncoder) (core.List, error) {
+	list := make(core.List, 0, 2)
+	values := make(core.List, 1)
+	var err error
+	values[0].Value = teff.FormatString(x.Email)
+	list = append(list, core.Node{Value: "Email:", List: values[0:1:1]})
+	if list, err = s.MarshalKey(list, "Since:", &x.Since); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+// UnmarshalTEFF decodes list into x.
+func (x *Owner) UnmarshalTEFF(list core.List) error {
+	return teff.UnmarshalList(list, x)
+}
+
+// UnmarshalTEFFKey decodes the child list of the key node into the field of
+// x with the state of the decoder, and reports whether there is the field.
+func (x *Owner) UnmarshalTEFFKey(d teffDecoder, node *core.Node) bool {
+	switch node.Value {
+	case "Email:":
+		if n, ok := d.Scalar(node.List, &x.Email); ok {
+			if v, err := teff.ParseString(n.Value); err != nil {
+				d.Error(n, &x.Email, err)
+			} else {
+				x.Email = v
+			}
+		}
+	case "Since:":
+		d.UnmarshalList(node.List, &x.Since)
+	default:
+		return false
+	}
+	return true
+}
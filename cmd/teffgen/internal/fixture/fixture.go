@@ -0,0 +1,35 @@
+// Package fixture holds the types that teffgen is tested and benchmarked
+// with.
+package fixture
+
+import (
+	"net"
+	"time"
+)
+
+//go:generate go run h12.io/teff/cmd/teffgen -type Config,Owner
+
+type Config struct {
+	Name     string `teff:"name"`
+	Port     uint16
+	Ratio    float64
+	Debug    bool `teff:",omitempty"`
+	Limit    Celsius
+	Retries  *int
+	Tags     []string
+	Weights  []float32 `teff:"weights,omitempty"`
+	Hosts    []net.IP
+	Owner    *Owner `teff:",omitempty"`
+	Admins   []Owner
+	Backups  []*Owner
+	Token    string `teff:",redact"`
+	Internal string `teff:"-"`
+	private  int
+}
+
+type Owner struct {
+	Email string
+	Since time.Time
+}
+
+type Celsius float64
@@ -0,0 +1,275 @@
+package fixture
+
+import (
+	"bytes"
+	"h12.io/teff"
+	"h12.io/teff/core"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// plainConfig and plainOwner have the same fields as Config and Owner but no
+// generated methods, so they are encoded by reflection.
+type plainConfig struct {
+	Name     string `teff:"name"`
+	Port     uint16
+	Ratio    float64
+	Debug    bool `teff:",omitempty"`
+	Limit    Celsius
+	Retries  *int
+	Tags     []string
+	Weights  []float32 `teff:"weights,omitempty"`
+	Hosts    []net.IP
+	Owner    *plainOwner `teff:",omitempty"`
+	Admins   []plainOwner
+	Backups  []*plainOwner
+	Token    string `teff:",redact"`
+	Internal string `teff:"-"`
+	private  int
+}
+
+type plainOwner struct {
+	Email string
+	Since time.Time
+}
+
+const testConfig = `name:
+	web
+Port:
+	8080
+Ratio:
+	0.75
+Debug:
+	true
+Limit:
+	-12.5
+Retries:
+	3
+Tags:
+	a
+	"b\nc"
+weights:
+	0.1
+	2
+Hosts:
+	10.0.0.1
+	::1
+Owner:
+	Email:
+		a@example.com
+	Since:
+		2015-01-02T03:04:05Z
+Admins:
+	_
+		Email:
+			b@example.com
+		Since:
+			0001-01-01T00:00:00Z
+Backups:
+	nil
+	_
+		Email:
+			c@example.com
+		Since:
+			2015-01-02T03:04:05.5+08:00
+Token:
+	<redacted>`
+
+func TestGenerated(t *testing.T) {
+	for i, text := range []string{
+		testConfig,
+		"name:\n\tx\nPort:\n\t0\nRatio:\n\t0\nLimit:\n\t0\nRetries:\n\tnil\nTags:\nHosts:\nAdmins:\nBackups:\nToken:\n\t<redacted>",
+		"name:\n\tx\nPort:\n\t0\nRatio:\n\t0\nLimit:\n\t0\nRetries:\n\tnil\nTags:\n\tnil\nHosts:\n\tnil\nAdmins:\n\tnil\nBackups:\n\tnil\nToken:\n\t<redacted>",
+		"name:\n\tx\nPort:\n\t0\nRatio:\n\t0\nLimit:\n\t0\nRetries:\n\tnil\nTags:\nHosts:\nAdmins:\nBackups:\n\t#<list>\n\t_\n\t\tnil\nToken:\n\t<redacted>",
+	} {
+		var generated Config
+		if err := teff.Unmarshal([]byte(text), &generated); err != nil {
+			t.Fatalf("testcase %d: %v", i, err)
+		}
+		var plain plainConfig
+		if err := teff.Unmarshal([]byte(text), &plain); err != nil {
+			t.Fatalf("testcase %d: %v", i, err)
+		}
+		generatedText, err := teff.Marshal(&generated)
+		if err != nil {
+			t.Fatalf("testcase %d: %v", i, err)
+		}
+		plainText, err := teff.Marshal(&plain)
+		if err != nil {
+			t.Fatalf("testcase %d: %v", i, err)
+		}
+		if string(generatedText) != text || string(plainText) != text {
+			t.Fatalf("testcase %d: expect\n%s\nbut got\n%s\nand\n%s", i, text, generatedText, plainText)
+		}
+	}
+}
+
+func TestGeneratedState(t *testing.T) {
+	since := time.Date(2015, 1, 2, 3, 4, 5, 0, time.UTC)
+	owner := &Owner{Email: "a@example.com", Since: since}
+	generated := Config{Name: "x", Owner: owner, Backups: []*Owner{owner, nil, owner}}
+	plain := plainConfig{Name: "x", Owner: &plainOwner{Email: "a@example.com", Since: since}}
+	plain.Backups = []*plainOwner{plain.Owner, nil, plain.Owner}
+	for i, testcase := range []struct {
+		encode   func(enc *teff.Encoder)
+		redacted bool
+	}{
+		{func(enc *teff.Encoder) {}, false},
+		{func(enc *teff.Encoder) { enc.CollapseNil() }, false},
+		{func(enc *teff.Encoder) { enc.Redact("^Owner:Email") }, true},
+	} {
+		encode := testcase.encode
+		var generatedText, plainText bytes.Buffer
+		enc := teff.NewEncoder(&generatedText)
+		encode(enc)
+		if err := enc.Encode(&generated); err != nil {
+			t.Fatalf("testcase %d: %v", i, err)
+		}
+		enc = teff.NewEncoder(&plainText)
+		encode(enc)
+		if err := enc.Encode(&plain); err != nil {
+			t.Fatalf("testcase %d: %v", i, err)
+		}
+		if generatedText.String() != plainText.String() {
+			t.Fatalf("testcase %d: expect\n%s\nbut got\n%s", i, plainText.String(), generatedText.String())
+		}
+		if redacted := strings.Contains(generatedText.String(), "Email:\n\t\t"+teff.RedactedValue); redacted != testcase.redacted {
+			t.Fatalf("testcase %d: expect redacted %v but got\n%s", i, testcase.redacted, generatedText.String())
+		}
+	}
+	text, err := teff.Marshal(&generated)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded Config
+	if err := teff.Unmarshal(text, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Owner != decoded.Backups[0] || decoded.Owner != decoded.Backups[2] {
+		t.Fatalf("expect shared owners but got %v", decoded)
+	}
+}
+
+func TestGeneratedError(t *testing.T) {
+	for i, text := range []string{
+		"Port:\n\tx",
+		"Port:\n\t1\n\t2",
+		"Port:\n\t^1",
+		"Port:\n\t1\n\t\t2",
+		"Port:\nPort:\n\t1",
+		"Unknown:\n\t1",
+		"Owner:\n\tEmail:\n\t\ta\n\tEmail:\n\t\tb\n\tName:\n\t\tc",
+		"Admins:\n\t_\n\t\tSince:\n\t\t\tx",
+		"Tags:\n\t_\nx",
+		"weights:\n\t1\n\tx\n\t^1",
+		"Admins:\n\t_\n\t\tEmail:\n\t\t\t^1\nName:\n\tx",
+	} {
+		for _, unmarshal := range []func([]byte, interface{}) error{teff.Unmarshal, teff.UnmarshalStrict} {
+			generatedErr := unmarshal([]byte(text), &Config{})
+			plainErr := unmarshal([]byte(text), &plainConfig{})
+			if generatedErr == nil || plainErr == nil {
+				if generatedErr != plainErr {
+					t.Fatalf("testcase %d: expect %v but got %v", i, plainErr, generatedErr)
+				}
+				continue
+			}
+			expected := strings.Replace(plainErr.Error(), "plainConfig", "Config", -1)
+			expected = strings.Replace(expected, "plainOwner", "Owner", -1)
+			if generatedErr.Error() != expected {
+				t.Fatalf("testcase %d: expect\n%s\nbut got\n%s", i, expected, generatedErr)
+			}
+		}
+	}
+}
+
+func BenchmarkMarshalGenerated(b *testing.B) {
+	var v Config
+	benchmarkMarshal(b, &v)
+}
+
+func BenchmarkMarshalReflect(b *testing.B) {
+	var v plainConfig
+	benchmarkMarshal(b, &v)
+}
+
+func BenchmarkUnmarshalGenerated(b *testing.B) {
+	benchmarkUnmarshal(b, func() interface{} { return &Config{} })
+}
+
+func BenchmarkUnmarshalReflect(b *testing.B) {
+	benchmarkUnmarshal(b, func() interface{} { return &plainConfig{} })
+}
+
+// The list benchmarks leave out the formatting and parsing of the text, which
+// do not depend on the generated methods.
+
+func BenchmarkMarshalListGenerated(b *testing.B) {
+	var v Config
+	benchmarkMarshalList(b, &v)
+}
+
+func BenchmarkMarshalListReflect(b *testing.B) {
+	var v plainConfig
+	benchmarkMarshalList(b, &v)
+}
+
+func BenchmarkUnmarshalListGenerated(b *testing.B) {
+	benchmarkUnmarshalList(b, func() interface{} { return &Config{} })
+}
+
+func BenchmarkUnmarshalListReflect(b *testing.B) {
+	benchmarkUnmarshalList(b, func() interface{} { return &plainConfig{} })
+}
+
+func benchmarkMarshal(b *testing.B, v interface{}) {
+	if err := teff.Unmarshal([]byte(testConfig), v); err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := teff.Marshal(v); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func benchmarkUnmarshal(b *testing.B, newValue func() interface{}) {
+	data := []byte(testConfig)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := teff.Unmarshal(data, newValue()); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func benchmarkMarshalList(b *testing.B, v interface{}) {
+	if err := teff.Unmarshal([]byte(testConfig), v); err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := teff.MarshalList(v); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func benchmarkUnmarshalList(b *testing.B, newValue func() interface{}) {
+	list, err := core.Parse(strings.NewReader(testConfig))
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := teff.UnmarshalList(list, newValue()); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
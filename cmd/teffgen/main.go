@@ -0,0 +1,67 @@
+// Command teffgen generates MarshalTEFF and UnmarshalTEFF methods for struct
+// types, so that their fields are encoded and decoded without reflection. The
+// generated methods are called by the encoder and the decoder with their
+// state, so they produce the same output and errors as the reflective codec
+// under any options, e.g. CollapseNil and Strict.
+//
+// Usage:
+//
+//	teffgen -type T1,T2 [-output file] [dir]
+//
+// It is usually run by go generate with a directive like
+//
+//	//go:generate teffgen -type Config,Owner
+//
+// Fields of bool, integer, float and string types, types defined on them, the
+// listed struct types and slices of them are encoded and decoded by the
+// generated code. Fields of other types, including pointers, are encoded and
+// decoded by the state of the encoder and the decoder, which keeps track of
+// the shared pointers.
+//
+// The generated file declares the types teffEncoder and teffDecoder of the
+// state, so the struct types of a package are listed in a single run.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var (
+	typeNames = flag.String("type", "", "comma-separated list of struct type names; must be set")
+	output    = flag.String("output", "", "output file name; default <dir>/<type>_teff.go")
+)
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: teffgen -type T1,T2 [-output file] [dir]")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if *typeNames == "" || flag.NArg() > 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	dir := "."
+	if flag.NArg() == 1 {
+		dir = flag.Arg(0)
+	}
+	names := strings.Split(*typeNames, ",")
+	src, err := generate(dir, names)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "teffgen:", err)
+		os.Exit(1)
+	}
+	file := *output
+	if file == "" {
+		file = filepath.Join(dir, strings.ToLower(names[0])+"_teff.go")
+	}
+	if err := ioutil.WriteFile(file, src, 0644); err != nil {
+		fmt.Fprintln(os.Stderr, "teffgen:", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,1004 @@
+package teff
+
+import (
+	"errors"
+	"fmt"
+	"h12.io/teff/core"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+var (
+	errMarshalUnsupported   = errors.New("marshal unsupported")
+	errUnmarshalUnsupported = errors.New("unmarshal unsupported")
+	errInvalidUnmarshal     = errors.New("unmarshal: expect a non-nil pointer")
+)
+
+// codec is the compiled encoding of a type. The list functions encode a value
+// as a list, e.g. the child list of a key, and the node functions encode it as
+// a single node, e.g. an element of a list. The unmarshal functions record
+// errors in the decodeState and go on with the rest of the input.
+type codec struct {
+	marshalList   func(e *encodeState, v reflect.Value) (core.List, error)
+	marshalNode   func(e *encodeState, v reflect.Value) (core.Node, error)
+	unmarshalList func(d *decodeState, list core.List, v reflect.Value)
+	unmarshalNode func(d *decodeState, node core.Node, v reflect.Value)
+}
+
+// fieldCodec is the compiled encoding of a struct field.
+type fieldCodec struct {
+	key       string
+	index     []int
+	omitEmpty bool
+	redact    bool
+	codec     *codec
+}
+
+// encodeState is the state of encoding a value. Each pointer encoded is given
+// an id, and the node of its first occurrence, or the reference to it, is
+// marked with the id once the node is in place, until finish labels the nodes
+// that are referred to.
+type encodeState struct {
+	collapseNil bool
+	ptrs        map[ptrKey]int
+	ids         map[*core.Node]int // id of the pointer a node encodes or refers to
+	aliases     map[int]int        // id of a pointer encoded as the node of another
+	pending     []int              // ids of pointers encoded but not in place yet
+}
+
+// ptrKey identifies a pointer by its type as well as its address, as a
+// pointer to a struct and one to its first field have the same address.
+type ptrKey struct {
+	addr uintptr
+	typ  reflect.Type
+}
+
+// pointer returns the id of the pointer v, and whether it is encoded before.
+func (e *encodeState) pointer(v reflect.Value) (int, bool) {
+	if e.ptrs == nil {
+		e.ptrs = make(map[ptrKey]int)
+	}
+	k := ptrKey{v.Pointer(), v.Type()}
+	if id, ok := e.ptrs[k]; ok {
+		return id, true
+	}
+	id := len(e.ptrs) + 1
+	e.ptrs[k] = id
+	return id, false
+}
+
+// mark marks node as the encoding of the pointer of id, or makes id an alias
+// if node already encodes another pointer.
+func (e *encodeState) mark(node *core.Node, id int) {
+	if to, ok := e.ids[node]; ok {
+		if e.aliases == nil {
+			e.aliases = make(map[int]int)
+		}
+		e.aliases[id] = to
+		return
+	}
+	if e.ids == nil {
+		e.ids = make(map[*core.Node]int)
+	}
+	e.ids[node] = id
+}
+
+// place marks node, in its final place in a list, as the encoding of the
+// pointers encoded as itself or as its child list.
+func (e *encodeState) place(node *core.Node) {
+	for _, id := range e.pending {
+		e.mark(node, id)
+	}
+	e.pending = e.pending[:0]
+}
+
+func (e *encodeState) resolve(id int) int {
+	for {
+		to, ok := e.aliases[id]
+		if !ok {
+			return id
+		}
+		id = to
+	}
+}
+
+// finish labels the marked nodes of list that are referred to with "# ^n" in
+// the order they appear, and sets the references accordingly. The pointers
+// still pending are encoded as list itself, and are referred to as the root.
+func (e *encodeState) finish(list core.List) {
+	if len(e.ptrs) == 0 {
+		return
+	}
+	var marked, refs []*core.Node
+	walk(list, func(n *core.Node) {
+		if _, ok := e.ids[n]; !ok {
+			return
+		} else if n.IsReference {
+			refs = append(refs, n)
+		} else {
+			marked = append(marked, n)
+		}
+	})
+	labels := make(map[int]string)
+	for _, id := range e.pending {
+		labels[e.resolve(id)] = ""
+	}
+	for _, ref := range refs {
+		if id := e.resolve(e.ids[ref]); !hasLabel(labels, id) {
+			labels[id] = "?"
+		}
+	}
+	n := 0
+	for _, node := range marked {
+		if id := e.ids[node]; labels[id] == "?" {
+			n++
+			labels[id] = strconv.Itoa(n)
+			node.SetRefLabel(labels[id])
+		}
+	}
+	for _, ref := range refs {
+		ref.Value = labels[e.resolve(e.ids[ref])]
+	}
+}
+
+func hasLabel(labels map[int]string, id int) bool {
+	_, ok := labels[id]
+	return ok
+}
+
+func walk(list core.List, visit func(n *core.Node)) {
+	for i := range list {
+		visit(&list[i])
+		walk(list[i].List, visit)
+	}
+}
+
+// decodeState is the state of decoding a value. path, line and label are
+// those of the key or the element whose value is being decoded, and ref is
+// the reference to it, if any.
+type decodeState struct {
+	strict bool
+	path   string
+	// key, or index if it is positive, is the key node or the index plus one
+	// of the value being decoded in the value at path when it is decoded by
+	// the methods generated by teffgen, which is joined to path only when it
+	// is needed.
+	key   *core.Node
+	index int
+	line  int
+	label string
+	ref   string
+	ptrs  map[refKey]reflect.Value
+	errs  UnmarshalErrors
+}
+
+// refKey identifies a decoded pointer by the reference to its node and its
+// type.
+type refKey struct {
+	ref string
+	typ reflect.Type
+}
+
+func newDecodeState(strict bool) *decodeState {
+	return &decodeState{strict: strict, path: "^", line: 1}
+}
+
+// register records the pointer v decoded from the node referred to by ref.
+func (d *decodeState) register(ref string, v reflect.Value) {
+	if ref == "" {
+		return
+	}
+	if d.ptrs == nil {
+		d.ptrs = make(map[refKey]reflect.Value)
+	}
+	d.ptrs[refKey{ref, v.Type()}] = v
+}
+
+// resolve sets the pointer v to the one decoded from the node that ref refers
+// to. It returns false if there is none but v points to a pointer, which may
+// then be resolved instead.
+func (d *decodeState) resolve(ref *core.Node, v reflect.Value) bool {
+	if p, ok := d.ptrs[refKey{"^" + ref.Value, v.Type()}]; ok {
+		v.Set(p)
+		return true
+	} else if v.Type().Elem().Kind() == reflect.Ptr {
+		return false
+	}
+	d.errorf(ref, v.Type(), "unresolved reference")
+	return true
+}
+
+// refOf returns the reference to node if it is labeled, or "" otherwise.
+func refOf(node *core.Node) string {
+	if id := node.RefLabel(); id != "" {
+		return "^" + id
+	}
+	return ""
+}
+
+// errorf records an error decoding node, or the current value if node is nil,
+// into a value of type t.
+func (d *decodeState) errorf(node *core.Node, t reflect.Type, format string, args ...interface{}) {
+	d.error(node, t, fmt.Errorf(format, args...))
+}
+
+func (d *decodeState) error(node *core.Node, t reflect.Type, err error) {
+	d.join()
+	if es, ok := err.(UnmarshalErrors); ok {
+		for _, e := range es {
+			e.Path = joinPath(d.path, e.Path)
+		}
+		d.errs = append(d.errs, es...)
+		return
+	}
+	e := &UnmarshalError{Line: d.line, Path: d.path, Type: t, Err: err}
+	if node != nil {
+		e.Line, e.Value = node.Line, node.Value
+		if node.IsReference {
+			e.Value = "^" + node.Value
+		}
+	}
+	d.errs = append(d.errs, e)
+}
+
+// err returns the recorded errors or nil.
+func (d *decodeState) err() error {
+	if len(d.errs) == 0 {
+		return nil
+	}
+	return d.errs
+}
+
+var codecs sync.Map // map[reflect.Type]*codec
+
+func marshalList(e *encodeState, v reflect.Value) (core.List, error) {
+	list, err := codecOf(v.Type()).marshalList(e, v)
+	if err != nil {
+		return nil, err
+	}
+	e.finish(list)
+	return list, nil
+}
+
+// unmarshalList decodes list into v, which is the root "^" that a reference
+// may refer to.
+func unmarshalList(d *decodeState, list core.List, v reflect.Value) error {
+	if v.CanAddr() {
+		d.register("^", v.Addr())
+	}
+	d.ref = "^"
+	codecOf(v.Type()).unmarshalList(d, list, v)
+	return d.err()
+}
+
+func unmarshalNode(d *decodeState, node core.Node, v reflect.Value) error {
+	codecOf(v.Type()).unmarshalNode(d, node, v)
+	return d.err()
+}
+
+// codecOf returns the codec of t, which is compiled once and cached.
+func codecOf(t reflect.Type) *codec {
+	if c, ok := codecs.Load(t); ok {
+		return c.(*codec)
+	}
+	// store a codec waiting for the compilation first, so that a recursive
+	// type refers to it instead of compiling itself again.
+	var wg sync.WaitGroup
+	var c *codec
+	wg.Add(1)
+	waiting := &codec{
+		marshalList: func(e *encodeState, v reflect.Value) (core.List, error) {
+			wg.Wait()
+			return c.marshalList(e, v)
+		},
+		marshalNode: func(e *encodeState, v reflect.Value) (core.Node, error) {
+			wg.Wait()
+			return c.marshalNode(e, v)
+		},
+		unmarshalList: func(d *decodeState, list core.List, v reflect.Value) {
+			wg.Wait()
+			c.unmarshalList(d, list, v)
+		},
+		unmarshalNode: func(d *decodeState, node core.Node, v reflect.Value) {
+			wg.Wait()
+			c.unmarshalNode(d, node, v)
+		},
+	}
+	if actual, loaded := codecs.LoadOrStore(t, waiting); loaded {
+		return actual.(*codec)
+	}
+	c = newCodec(t)
+	wg.Done()
+	codecs.Store(t, c)
+	return c
+}
+
+func newCodec(t reflect.Type) *codec {
+	c := &codec{}
+	if ext, ok := extensions[t]; ok {
+		c.scalar(t, func(e *encodeState, v reflect.Value) (core.Node, error) {
+			return core.Node{Value: ext.marshal(v)}, nil
+		}, ext.unmarshal)
+		c.withMarshaler(t)
+		return c
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		c.scalar(t, func(e *encodeState, v reflect.Value) (core.Node, error) {
+			return core.Node{Value: strconv.FormatBool(v.Bool())}, nil
+		}, func(s string, v reflect.Value) error {
+			b, err := strconv.ParseBool(s)
+			if err != nil {
+				return err
+			}
+			v.SetBool(b)
+			return nil
+		})
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		bits := t.Bits()
+		c.scalar(t, func(e *encodeState, v reflect.Value) (core.Node, error) {
+			return core.Node{Value: strconv.FormatInt(v.Int(), 10)}, nil
+		}, func(s string, v reflect.Value) error {
+			i, err := strconv.ParseInt(s, 10, bits)
+			if err != nil {
+				return err
+			}
+			v.SetInt(i)
+			return nil
+		})
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		bits := t.Bits()
+		c.scalar(t, func(e *encodeState, v reflect.Value) (core.Node, error) {
+			return core.Node{Value: strconv.FormatUint(v.Uint(), 10)}, nil
+		}, func(s string, v reflect.Value) error {
+			u, err := strconv.ParseUint(s, 10, bits)
+			if err != nil {
+				return err
+			}
+			v.SetUint(u)
+			return nil
+		})
+	case reflect.Float32, reflect.Float64:
+		bits := t.Bits()
+		c.scalar(t, func(e *encodeState, v reflect.Value) (core.Node, error) {
+			return core.Node{Value: strconv.FormatFloat(v.Float(), 'g', -1, bits)}, nil
+		}, func(s string, v reflect.Value) error {
+			f, err := strconv.ParseFloat(s, bits)
+			if err != nil {
+				return err
+			}
+			v.SetFloat(f)
+			return nil
+		})
+	case reflect.Complex64, reflect.Complex128:
+		bits := t.Bits()
+		c.scalar(t, func(e *encodeState, v reflect.Value) (core.Node, error) {
+			s := strconv.FormatComplex(v.Complex(), 'g', -1, bits)
+			return core.Node{Value: s[1 : len(s)-1]}, nil
+		}, func(s string, v reflect.Value) error {
+			x, err := strconv.ParseComplex(s, bits)
+			if err != nil {
+				return err
+			}
+			v.SetComplex(x)
+			return nil
+		})
+	case reflect.String:
+		c.scalar(t, func(e *encodeState, v reflect.Value) (core.Node, error) {
+			return core.Node{Value: FormatString(v.String())}, nil
+		}, func(s string, v reflect.Value) error {
+			str, err := ParseString(s)
+			if err != nil {
+				return err
+			}
+			v.SetString(str)
+			return nil
+		})
+	case reflect.Struct:
+		c.structCodec(t)
+	case reflect.Slice:
+		c.sliceCodec(t)
+	case reflect.Array:
+		c.arrayCodec(t)
+	case reflect.Map:
+		c.mapCodec(t)
+	case reflect.Ptr:
+		c.ptrCodec(t)
+	case reflect.Interface:
+		c.interfaceCodec(t)
+		if t.NumMethod() == 0 {
+			c.anyCodec(t)
+		}
+	default:
+		c.scalar(t, func(e *encodeState, v reflect.Value) (core.Node, error) {
+			return core.Node{}, errMarshalUnsupported
+		}, func(s string, v reflect.Value) error {
+			return errUnmarshalUnsupported
+		})
+	}
+	c.withMarshaler(t)
+	return c
+}
+
+// scalar sets the functions of a type t encoded as a single value node.
+func (c *codec) scalar(t reflect.Type, marshalNode func(e *encodeState, v reflect.Value) (core.Node, error), unmarshalValue func(s string, v reflect.Value) error) {
+	c.marshalNode = marshalNode
+	c.marshalList = func(e *encodeState, v reflect.Value) (core.List, error) {
+		node, err := marshalNode(e, v)
+		if err != nil {
+			return nil, err
+		}
+		list := core.List{node}
+		e.place(&list[0])
+		return list, nil
+	}
+	c.unmarshalNode = func(d *decodeState, node core.Node, v reflect.Value) {
+		if d.strict {
+			if node.IsReference {
+				d.errorf(&node, t, "unexpected reference")
+				return
+			} else if len(node.List) > 0 {
+				d.errorf(&node.List[0], t, "unexpected child")
+				return
+			}
+		}
+		if err := unmarshalValue(node.Value, v); err != nil {
+			d.error(&node, t, err)
+		}
+	}
+	unmarshalNode := c.unmarshalNode
+	c.unmarshalList = func(d *decodeState, list core.List, v reflect.Value) {
+		switch {
+		case d.strict && len(list) > 1:
+			d.errorf(&list[1], t, "unexpected node after a single value")
+		case len(list) != 1:
+			d.errorf(nil, t, "expect a single value but got %d nodes", len(list))
+		default:
+			unmarshalNode(d, list[0], v)
+		}
+	}
+}
+
+func (c *codec) structCodec(t reflect.Type) {
+	var fields []fieldCodec
+	keys := make(map[string]*fieldCodec)
+	for _, f := range StructFields(t) {
+		fields = append(fields, fieldCodec{key: f.Name + ":", index: f.Index, omitEmpty: f.OmitEmpty, redact: f.Redact, codec: codecOf(f.Type)})
+	}
+	for i := range fields {
+		if _, ok := keys[fields[i].key]; !ok {
+			keys[fields[i].key] = &fields[i]
+		}
+	}
+	c.marshalList = func(e *encodeState, v reflect.Value) (core.List, error) {
+		list := make(core.List, 0, len(fields))
+		for i := range fields {
+			f := &fields[i]
+			fv := v.FieldByIndex(f.index)
+			if f.omitEmpty && isEmptyValue(fv) {
+				continue
+			}
+			if f.redact {
+				list = append(list, core.Node{Value: f.key, List: core.List{{Value: RedactedValue}}})
+				continue
+			}
+			children, err := f.codec.marshalList(e, fv)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, core.Node{Value: f.key, List: children})
+			e.place(&list[len(list)-1])
+		}
+		return list, nil
+	}
+	c.unmarshalList = func(d *decodeState, list core.List, v reflect.Value) {
+		path, line, label, ref := d.path, d.line, d.label, d.ref
+		defer func() { d.path, d.line, d.label, d.ref = path, line, label, ref }()
+		var seen map[string]bool
+		if d.strict {
+			seen = make(map[string]bool, len(list))
+		}
+		for i := range list {
+			node := &list[i]
+			f, ok := keys[node.Value]
+			if d.strict && !isKey(*node) {
+				d.path, d.line = path, line
+				d.errorf(node, t, "expect a key")
+				continue
+			}
+			key := strings.TrimSuffix(node.Value, ":")
+			d.path, d.line = core.JoinKey(path, key), node.Line
+			if d.strict {
+				if !ok {
+					d.errorf(node, t, "unknown key %q", key)
+					continue
+				} else if seen[node.Value] {
+					d.errorf(node, t, "duplicate key %q", key)
+					continue
+				}
+				seen[node.Value] = true
+			}
+			if ok && !(f.redact && isRedacted(node.List)) {
+				d.label, d.ref = node.TypeLabel(), refOf(node)
+				f.codec.unmarshalList(d, node.List, v.FieldByIndex(f.index))
+			}
+		}
+	}
+	marshalList, unmarshalList := c.marshalList, c.unmarshalList
+	c.marshalNode = func(e *encodeState, v reflect.Value) (core.Node, error) {
+		list, err := marshalList(e, v)
+		if err != nil {
+			return core.Node{}, err
+		}
+		return core.Node{Value: "_", List: list}, nil
+	}
+	c.unmarshalNode = func(d *decodeState, node core.Node, v reflect.Value) {
+		if d.strict && (node.Value != "_" || node.IsReference) {
+			d.errorf(&node, t, "expect _")
+			return
+		}
+		unmarshalList(d, node.List, v)
+	}
+}
+
+// sliceCodec encodes a nil slice as nil, and a slice of a single nil element
+// as a labeled list so that it is not read as a nil slice.
+func (c *codec) sliceCodec(t reflect.Type) {
+	elem := codecOf(t.Elem())
+	c.marshalList = func(e *encodeState, v reflect.Value) (core.List, error) {
+		if v.IsNil() && !e.collapseNil {
+			return core.List{{Value: "nil"}}, nil
+		}
+		list := make(core.List, v.Len())
+		for i := range list {
+			node, err := elem.marshalNode(e, v.Index(i))
+			if err != nil {
+				return nil, err
+			}
+			list[i] = node
+			e.place(&list[i])
+		}
+		if len(list) == 1 && isNil(list[0]) {
+			return labeledList(list), nil
+		}
+		return list, nil
+	}
+	c.unmarshalList = func(d *decodeState, list core.List, v reflect.Value) {
+		if isLabeledList(list) {
+			list = list[0].List
+		} else if len(list) == 1 && isNil(list[0]) {
+			v.Set(reflect.Zero(t))
+			return
+		}
+		if v.IsNil() {
+			v.Set(reflect.MakeSlice(t, 0, len(list)))
+		}
+		path, line := d.path, d.line
+		defer func() { d.path, d.line = path, line }()
+		for i, node := range list {
+			d.path, d.line = core.JoinIndex(path, i), node.Line
+			v.Set(reflect.Append(v, reflect.Zero(t.Elem())))
+			elem.unmarshalNode(d, node, v.Index(v.Len()-1))
+		}
+	}
+	c.elements(t)
+}
+
+// arrayCodec is like sliceCodec but the length of a list must be that of the
+// array.
+func (c *codec) arrayCodec(t reflect.Type) {
+	elem := codecOf(t.Elem())
+	c.marshalList = func(e *encodeState, v reflect.Value) (core.List, error) {
+		list := make(core.List, v.Len())
+		for i := range list {
+			node, err := elem.marshalNode(e, v.Index(i))
+			if err != nil {
+				return nil, err
+			}
+			list[i] = node
+			e.place(&list[i])
+		}
+		return list, nil
+	}
+	c.unmarshalList = func(d *decodeState, list core.List, v reflect.Value) {
+		if len(list) != t.Len() {
+			d.errorf(nil, t, "expect %d elements but got %d", t.Len(), len(list))
+			return
+		}
+		path, line := d.path, d.line
+		defer func() { d.path, d.line = path, line }()
+		for i, node := range list {
+			d.path, d.line = core.JoinIndex(path, i), node.Line
+			elem.unmarshalNode(d, node, v.Index(i))
+		}
+	}
+	c.elements(t)
+}
+
+func (c *codec) mapCodec(t reflect.Type) {
+	key, elem := codecOf(t.Key()), codecOf(t.Elem())
+	switch t.Key().Kind() {
+	case reflect.Bool, reflect.String,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64:
+	default:
+		c.scalar(t, func(e *encodeState, v reflect.Value) (core.Node, error) {
+			return core.Node{}, errMarshalUnsupported
+		}, func(s string, v reflect.Value) error {
+			return errUnmarshalUnsupported
+		})
+		return
+	}
+	c.marshalList = func(e *encodeState, v reflect.Value) (core.List, error) {
+		if v.IsNil() && !e.collapseNil {
+			return core.List{{Value: "nil"}}, nil
+		}
+		keys, values := make([]reflect.Value, 0, v.Len()), make([]reflect.Value, 0, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			keys, values = append(keys, iter.Key()), append(values, iter.Value())
+		}
+		sort.Sort(byKey{keys, values})
+		list := make(core.List, 0, len(keys))
+		for i := range keys {
+			k, err := key.marshalNode(e, keys[i])
+			if err != nil {
+				return nil, err
+			}
+			children, err := elem.marshalList(e, values[i])
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, core.Node{Value: k.Value + ":", List: children})
+			e.place(&list[len(list)-1])
+		}
+		return list, nil
+	}
+	c.unmarshalList = func(d *decodeState, list core.List, v reflect.Value) {
+		if len(list) == 1 && isNil(list[0]) {
+			v.Set(reflect.Zero(t))
+			return
+		}
+		if v.IsNil() {
+			v.Set(reflect.MakeMapWithSize(t, len(list)))
+		}
+		path, line, label, ref := d.path, d.line, d.label, d.ref
+		defer func() { d.path, d.line, d.label, d.ref = path, line, label, ref }()
+		var seen map[string]bool
+		if d.strict {
+			seen = make(map[string]bool, len(list))
+		}
+		for i := range list {
+			node := &list[i]
+			if !isKey(*node) {
+				if d.strict {
+					d.path, d.line = path, line
+					d.errorf(node, t, "expect a key")
+				}
+				continue
+			}
+			k := strings.TrimSuffix(node.Value, ":")
+			d.path, d.line = core.JoinKey(path, k), node.Line
+			if d.strict {
+				if seen[node.Value] {
+					d.errorf(node, t, "duplicate key %q", k)
+					continue
+				}
+				seen[node.Value] = true
+			}
+			n := len(d.errs)
+			kv := reflect.New(t.Key()).Elem()
+			key.unmarshalNode(d, core.Node{Value: k, Line: node.Line}, kv)
+			ev := reflect.New(t.Elem()).Elem()
+			d.label, d.ref = node.TypeLabel(), refOf(node)
+			elem.unmarshalList(d, node.List, ev)
+			if len(d.errs) == n {
+				v.SetMapIndex(kv, ev)
+			}
+		}
+	}
+	c.elements(t)
+}
+
+// byKey sorts the entries of a map by the value of their keys, so that
+// numbers are in numeric order and a NaN comes last.
+type byKey struct {
+	keys, values []reflect.Value
+}
+
+func (s byKey) Len() int { return len(s.keys) }
+
+func (s byKey) Swap(i, j int) {
+	s.keys[i], s.keys[j] = s.keys[j], s.keys[i]
+	s.values[i], s.values[j] = s.values[j], s.values[i]
+}
+
+func (s byKey) Less(i, j int) bool {
+	a, b := s.keys[i], s.keys[j]
+	switch a.Kind() {
+	case reflect.Bool:
+		return !a.Bool() && b.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return a.Int() < b.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return a.Uint() < b.Uint()
+	case reflect.Float32, reflect.Float64:
+		x, y := a.Float(), b.Float()
+		return x < y || math.IsNaN(y) && !math.IsNaN(x)
+	}
+	return a.String() < b.String()
+}
+
+// elements sets the node functions of a list type t, which is encoded as an
+// element of another list with the anonymous parent "_", or as nil if t is
+// not an array.
+func (c *codec) elements(t reflect.Type) {
+	nullable := t.Kind() != reflect.Array
+	marshalList, unmarshalList := c.marshalList, c.unmarshalList
+	c.marshalNode = func(e *encodeState, v reflect.Value) (core.Node, error) {
+		if nullable && v.IsNil() && !e.collapseNil {
+			return core.Node{Value: "nil"}, nil
+		}
+		list, err := marshalList(e, v)
+		if err != nil {
+			return core.Node{}, err
+		}
+		return core.Node{Value: "_", List: list}, nil
+	}
+	c.unmarshalNode = func(d *decodeState, node core.Node, v reflect.Value) {
+		if nullable && isNil(node) {
+			v.Set(reflect.Zero(t))
+			return
+		} else if node.Value != "_" || node.IsReference {
+			d.errorf(&node, t, "expect _")
+			return
+		}
+		d.line = node.Line
+		unmarshalList(d, node.List, v)
+	}
+}
+
+// ptrCodec encodes a pointer encoded before as a reference to the node of its
+// first occurrence. A pointer to a zero-size value is never shared, as it may
+// have the same address as another.
+func (c *codec) ptrCodec(t reflect.Type) {
+	elem := codecOf(t.Elem())
+	shared := t.Elem().Size() > 0
+	c.marshalList = func(e *encodeState, v reflect.Value) (core.List, error) {
+		if v.IsNil() {
+			return core.List{{Value: "nil"}}, nil
+		} else if !shared {
+			return elem.marshalList(e, v.Elem())
+		}
+		id, seen := e.pointer(v)
+		if seen {
+			list := core.List{{IsReference: true}}
+			e.mark(&list[0], id)
+			return list, nil
+		}
+		list, err := elem.marshalList(e, v.Elem())
+		e.pending = append(e.pending, id)
+		return list, err
+	}
+	c.marshalNode = func(e *encodeState, v reflect.Value) (core.Node, error) {
+		if v.IsNil() {
+			return core.Node{Value: "nil"}, nil
+		} else if !shared {
+			return elem.marshalNode(e, v.Elem())
+		}
+		id, seen := e.pointer(v)
+		if seen {
+			e.pending = append(e.pending, id)
+			return core.Node{IsReference: true}, nil
+		}
+		node, err := elem.marshalNode(e, v.Elem())
+		e.pending = append(e.pending, id)
+		return node, err
+	}
+	c.unmarshalList = func(d *decodeState, list core.List, v reflect.Value) {
+		if len(list) == 1 && list[0].IsReference && d.resolve(&list[0], v) {
+			return
+		} else if len(list) == 1 && isNil(list[0]) {
+			v.Set(reflect.Zero(t))
+			return
+		}
+		if v.IsNil() {
+			v.Set(reflect.New(t.Elem()))
+		}
+		d.register(d.ref, v)
+		elem.unmarshalList(d, list, v.Elem())
+	}
+	c.unmarshalNode = func(d *decodeState, node core.Node, v reflect.Value) {
+		if node.IsReference && d.resolve(&node, v) {
+			return
+		} else if isNil(node) {
+			v.Set(reflect.Zero(t))
+			return
+		}
+		if v.IsNil() {
+			v.Set(reflect.New(t.Elem()))
+		}
+		d.register(refOf(&node), v)
+		elem.unmarshalNode(d, node, v.Elem())
+	}
+}
+
+// interfaceCodec encodes the dynamic value of an interface so that it is
+// decoded back into an empty interface as the same value. A string is quoted if
+// it could be read as a value of another type. A float is written with a
+// fraction if it is integral, and without leading zeros in its exponent. A nil
+// or empty map and a nil slice are written with a type label, and a list of a
+// single element as a labeled list, so that they are read as neither a list
+// nor a scalar.
+func (c *codec) interfaceCodec(t reflect.Type) {
+	c.scalar(t, func(e *encodeState, v reflect.Value) (core.Node, error) {
+		if v.IsNil() {
+			return core.Node{Value: "nil"}, nil
+		}
+		ev := v.Elem()
+		if ev.Kind() == reflect.String && !ev.Type().Implements(marshalerType) {
+			return core.Node{Value: FormatUntyped(ev.String())}, nil
+		}
+		node, err := codecOf(ev.Type()).marshalNode(e, ev)
+		if err != nil || ev.Type().Implements(marshalerType) {
+			return node, err
+		}
+		switch k := ev.Kind(); {
+		case k == reflect.Float32 || k == reflect.Float64 || k == reflect.Complex64 || k == reflect.Complex128:
+			node.Value = expZeros.ReplaceAllString(node.Value, "$1$2")
+			if intPattern.MatchString(node.Value) {
+				node.Value += ".0"
+			}
+		case k == reflect.Map && (isNil(node) || node.Value == "_" && len(node.List) == 0):
+			node.SetTypeLabel("map")
+		case k == reflect.Slice && isNil(node):
+			node.SetTypeLabel("list")
+		}
+		return node, nil
+	}, func(s string, v reflect.Value) error {
+		if s == "nil" {
+			v.Set(reflect.Zero(t))
+			return nil
+		}
+		return errUnmarshalUnsupported
+	})
+	marshalNode := c.marshalNode
+	c.marshalList = func(e *encodeState, v reflect.Value) (core.List, error) {
+		if !v.IsNil() {
+			ev := v.Elem()
+			switch ev.Kind() {
+			case reflect.String, reflect.Float32, reflect.Float64, reflect.Complex64, reflect.Complex128:
+			case reflect.Map:
+				if ev.Len() != 0 {
+					return codecOf(ev.Type()).marshalList(e, ev)
+				}
+			case reflect.Slice, reflect.Array:
+				if ev.Kind() == reflect.Slice && ev.IsNil() {
+					break
+				}
+				list, err := codecOf(ev.Type()).marshalList(e, ev)
+				if err != nil || ev.Len() != 1 || isLabeledList(list) || ev.Type().Implements(marshalerType) {
+					return list, err
+				}
+				return labeledList(list), nil
+			default:
+				return codecOf(ev.Type()).marshalList(e, ev)
+			}
+		}
+		node, err := marshalNode(e, v)
+		if err != nil {
+			return nil, err
+		}
+		list := core.List{node}
+		e.place(&list[0])
+		return list, nil
+	}
+}
+
+// withMarshaler makes the codec of a non-pointer type t call the methods of
+// Marshaler and Unmarshaler when they are implemented, or those generated by
+// teffgen in their stead.
+func (c *codec) withMarshaler(t reflect.Type) {
+	if t.Kind() == reflect.Ptr || t.Kind() == reflect.Interface {
+		return
+	}
+	if pt := reflect.PtrTo(t); t.Kind() == reflect.Struct && pt.Implements(stateMarshalerType) && pt.Implements(stateUnmarshalerType) {
+		c.withState(t)
+		return
+	}
+	valueMarshaler := t.Implements(marshalerType)
+	if valueMarshaler || reflect.PtrTo(t).Implements(marshalerType) {
+		marshalList, marshalNode := c.marshalList, c.marshalNode
+		marshaler := func(v reflect.Value) Marshaler {
+			if valueMarshaler {
+				return v.Interface().(Marshaler)
+			} else if v.CanAddr() {
+				return v.Addr().Interface().(Marshaler)
+			}
+			return nil
+		}
+		c.marshalList = func(e *encodeState, v reflect.Value) (core.List, error) {
+			if m := marshaler(v); m != nil {
+				return m.MarshalTEFF()
+			}
+			return marshalList(e, v)
+		}
+		c.marshalNode = func(e *encodeState, v reflect.Value) (core.Node, error) {
+			m := marshaler(v)
+			if m == nil {
+				return marshalNode(e, v)
+			}
+			list, err := m.MarshalTEFF()
+			if err != nil {
+				return core.Node{}, err
+			}
+			if len(list) == 1 && !isKey(list[0]) {
+				return list[0], nil
+			}
+			return core.Node{Value: "_", List: list}, nil
+		}
+	}
+	if reflect.PtrTo(t).Implements(unmarshalerType) {
+		unmarshalList, unmarshalNode := c.unmarshalList, c.unmarshalNode
+		c.unmarshalList = func(d *decodeState, list core.List, v reflect.Value) {
+			if !v.CanAddr() {
+				unmarshalList(d, list, v)
+				return
+			}
+			if err := v.Addr().Interface().(Unmarshaler).UnmarshalTEFF(list); err != nil {
+				var node *core.Node
+				if len(list) > 0 {
+					node = &list[0]
+				}
+				d.error(node, t, err)
+			}
+		}
+		c.unmarshalNode = func(d *decodeState, node core.Node, v reflect.Value) {
+			if !v.CanAddr() {
+				unmarshalNode(d, node, v)
+				return
+			}
+			list := core.List{node}
+			if node.Value == "_" {
+				list = node.List
+			}
+			if err := v.Addr().Interface().(Unmarshaler).UnmarshalTEFF(list); err != nil {
+				d.error(&node, t, err)
+			}
+		}
+	}
+}
+
+// joinPath returns the path of sub, a path relative to the value at path.
+func joinPath(path, sub string) string {
+	sub = strings.TrimPrefix(sub, "^")
+	if sub == "" || path == "^" || sub[0] == '[' || strings.HasSuffix(path, "]") {
+		return path + sub
+	}
+	return path + ":" + sub
+}
+
+// labeledList returns list as the child list of a "_" node labeled <list>, the
+// form of a list that would otherwise be read as a single value.
+func labeledList(list core.List) core.List {
+	node := core.Node{Value: "_", List: list}
+	node.SetTypeLabel("list")
+	return core.List{node}
+}
+
+func isLabeledList(list core.List) bool {
+	return len(list) == 1 && list[0].Value == "_" && !list[0].IsReference && list[0].TypeLabel() == "list"
+}
+
+func isKey(node core.Node) bool {
+	return !node.IsReference && strings.HasSuffix(node.Value, ":")
+}
+
+func isNil(node core.Node) bool {
+	return node.Value == "nil" && !node.IsReference && len(node.List) == 0
+}
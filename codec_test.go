@@ -0,0 +1,103 @@
+package teff
+
+import (
+	"h12.io/teff/core"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+type testTree struct {
+	Name     string
+	Children []testTree `teff:",omitempty"`
+	Next     *testTree  `teff:",omitempty"`
+}
+
+func TestCodecRecursive(t *testing.T) {
+	tree := testTree{Name: "a", Children: []testTree{{Name: "b"}}, Next: &testTree{Name: "c"}}
+	expected := "Name:\n\ta\nChildren:\n\t_\n\t\tName:\n\t\t\tb\nNext:\n\tName:\n\t\tc"
+	var wg sync.WaitGroup
+	errs := make([]error, 8)
+	results := make([]string, 8)
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			buf, err := Marshal(tree)
+			results[i], errs[i] = string(buf), err
+		}(i)
+	}
+	wg.Wait()
+	for i := range errs {
+		if errs[i] != nil {
+			t.Fatalf("testcase %d: %v", i, errs[i])
+		}
+		if results[i] != expected {
+			t.Fatalf("testcase %d: expect\n%s\nbut got\n%s", i, expected, results[i])
+		}
+	}
+	var actual testTree
+	if err := Unmarshal([]byte(expected), &actual); err != nil {
+		t.Fatal(err)
+	}
+	if actual.Name != "a" || len(actual.Children) != 1 || actual.Children[0].Name != "b" || actual.Next == nil || actual.Next.Name != "c" {
+		t.Fatalf("expect %#v but got %#v", tree, actual)
+	}
+}
+
+func TestCodecReference(t *testing.T) {
+	cycle := &testTree{Name: "a"}
+	cycle.Next = &testTree{Name: "b", Next: cycle}
+	self := &testTree{Name: "a", Next: &testTree{Name: "b"}}
+	self.Next.Next = self.Next
+	shared := &testTree{Name: "b"}
+	for i, testcase := range []struct {
+		value    interface{}
+		text     string
+		resolved func(v interface{}) bool
+	}{
+		{cycle, "Name:\n\ta\nNext:\n\tName:\n\t\tb\n\tNext:\n\t\t^", func(v interface{}) bool {
+			tree := v.(*testTree)
+			return tree.Next.Next == tree
+		}},
+		{self, "Name:\n\ta\n# ^1\nNext:\n\tName:\n\t\tb\n\tNext:\n\t\t^1", func(v interface{}) bool {
+			tree := v.(*testTree)
+			return tree.Next.Next == tree.Next
+		}},
+		{[]*testTree{shared, shared}, "# ^1\n_\n\tName:\n\t\tb\n^1", func(v interface{}) bool {
+			trees := *v.(*[]*testTree)
+			return trees[0] == trees[1]
+		}},
+		{map[string]*testTree{"a": shared, "b": shared}, "# ^1\na:\n\tName:\n\t\tb\nb:\n\t^1", func(v interface{}) bool {
+			trees := *v.(*map[string]*testTree)
+			return trees["a"] == trees["b"]
+		}},
+	} {
+		list, err := MarshalList(testcase.value)
+		if err != nil {
+			t.Fatalf("testcase %d: %v", i, err)
+		}
+		walk(list, func(n *core.Node) {
+			if n.Line != 0 {
+				t.Fatalf("testcase %d: expect no line but got %d", i, n.Line)
+			}
+		})
+		buf, err := Marshal(testcase.value)
+		if err != nil {
+			t.Fatalf("testcase %d: %v", i, err)
+		}
+		if string(buf) != testcase.text {
+			t.Fatalf("testcase %d: expect\n%s\nbut got\n%s", i, testcase.text, string(buf))
+		}
+		v := reflect.New(reflect.TypeOf(testcase.value))
+		if err := Unmarshal(buf, v.Interface()); err != nil {
+			t.Fatalf("testcase %d: %v", i, err)
+		}
+		if _, ok := testcase.value.(*testTree); ok {
+			v = v.Elem()
+		}
+		if !testcase.resolved(v.Interface()) {
+			t.Fatalf("testcase %d: references are not resolved", i)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package core
+
+import (
+	"strings"
+	"unicode"
+)
+
+type AnnotationKind int
+
+const (
+	// CommentAnnotation is a human comment, e.g. "# a note".
+	CommentAnnotation AnnotationKind = iota
+	// TypeAnnotation is a type label, e.g. "#<int>".
+	TypeAnnotation
+	// LabelAnnotation is a reference label, e.g. "# ^1".
+	LabelAnnotation
+)
+
+// ClassifyAnnotation returns the kind of annotation a (without the leading
+// "#"), together with the type name for a type label, the id for a reference
+// label or a itself for a comment. The id of a label is one or more
+// char_visible, so "# ^ see above" is a comment.
+func ClassifyAnnotation(a string) (AnnotationKind, string) {
+	s := strings.TrimLeft(a, " \t")
+	if strings.HasPrefix(s, "^") && isVisible(s[1:]) {
+		return LabelAnnotation, s[1:]
+	}
+	if len(s) > 2 && s[0] == '<' && s[len(s)-1] == '>' && isLetterDigits(s[1:len(s)-1]) {
+		return TypeAnnotation, s[1 : len(s)-1]
+	}
+	return CommentAnnotation, a
+}
+
+// isVisible reports whether s is a non-empty string of char_visible.
+func isVisible(s string) bool {
+	for _, r := range s {
+		if r <= ' ' {
+			return false
+		}
+	}
+	return s != ""
+}
+
+func isLetterDigits(s string) bool {
+	for _, r := range s {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_' {
+			return false
+		}
+	}
+	return true
+}
+
+// Comments returns the comment annotations of n.
+func (n *Node) Comments() []string {
+	var cs []string
+	for _, a := range n.Annotations {
+		if kind, _ := ClassifyAnnotation(a); kind == CommentAnnotation {
+			cs = append(cs, a)
+		}
+	}
+	return cs
+}
+
+// TypeLabel returns the type name of the type label of n or "" if none.
+func (n *Node) TypeLabel() string {
+	return n.label(TypeAnnotation)
+}
+
+// RefLabel returns the id of the reference label of n or "" if none.
+func (n *Node) RefLabel() string {
+	return n.label(LabelAnnotation)
+}
+
+// SetTypeLabel replaces the type label of n, or removes it if t is empty,
+// leaving other annotations untouched.
+func (n *Node) SetTypeLabel(t string) {
+	a := ""
+	if t != "" {
+		a = "<" + t + ">"
+	}
+	n.setLabel(TypeAnnotation, a)
+}
+
+// SetRefLabel replaces the reference label of n, or removes it if id is
+// empty, leaving other annotations untouched.
+func (n *Node) SetRefLabel(id string) {
+	a := ""
+	if id != "" {
+		a = " ^" + id
+	}
+	n.setLabel(LabelAnnotation, a)
+}
+
+func (n *Node) label(kind AnnotationKind) string {
+	for _, a := range n.Annotations {
+		if k, v := ClassifyAnnotation(a); k == kind {
+			return v
+		}
+	}
+	return ""
+}
+
+// setLabel keeps the lines of the annotations left, and the line of a
+// replaced label for the new one.
+func (n *Node) setLabel(kind AnnotationKind, a string) {
+	as := n.Annotations[:0:0]
+	var lines []int
+	for i, old := range n.Annotations {
+		if k, _ := ClassifyAnnotation(old); k == kind {
+			if a != "" {
+				as, lines = append(as, a), append(lines, n.annotationLine(i))
+				a = ""
+			}
+			continue
+		}
+		as, lines = append(as, old), append(lines, n.annotationLine(i))
+	}
+	if a != "" {
+		as, lines = append(as, a), append(lines, n.Line)
+	}
+	if len(as) == 0 {
+		as = nil
+	}
+	n.Annotations = as
+	if n.AnnotationLines != nil {
+		n.AnnotationLines = lines
+	}
+}
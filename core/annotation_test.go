@@ -0,0 +1,97 @@
+package core
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestClassifyAnnotation(t *testing.T) {
+	for i, testcase := range []struct {
+		a     string
+		kind  AnnotationKind
+		value string
+	}{
+		{" a note", CommentAnnotation, " a note"},
+		{"", CommentAnnotation, ""},
+		{"^", CommentAnnotation, "^"},
+		{"<>", CommentAnnotation, "<>"},
+		{"<a b>", CommentAnnotation, "<a b>"},
+		{"<int> x", CommentAnnotation, "<int> x"},
+		{"<int>", TypeAnnotation, "int"},
+		{" <时间_1>", TypeAnnotation, "时间_1"},
+		{"^1", LabelAnnotation, "1"},
+		{" ^a", LabelAnnotation, "a"},
+		{"^a[0]:b", LabelAnnotation, "a[0]:b"},
+		{" ^ see above", CommentAnnotation, " ^ see above"},
+		{"^1 2", CommentAnnotation, "^1 2"},
+		{"^1\t", CommentAnnotation, "^1\t"},
+	} {
+		kind, value := ClassifyAnnotation(testcase.a)
+		if kind != testcase.kind || value != testcase.value {
+			t.Fatalf("testcase %d: expect %d %q but got %d %q", i, testcase.kind, testcase.value, kind, value)
+		}
+	}
+}
+
+func TestNodeLabels(t *testing.T) {
+	n := Node{Value: "a", Annotations: []string{" c1", " ^1", " c2", "<int>"}}
+	if n.RefLabel() != "1" || n.TypeLabel() != "int" {
+		t.Fatalf("unexpected labels %q %q", n.RefLabel(), n.TypeLabel())
+	}
+	if cs := n.Comments(); !reflect.DeepEqual(cs, []string{" c1", " c2"}) {
+		t.Fatalf("unexpected comments %q", cs)
+	}
+	n.SetRefLabel("2")
+	n.SetTypeLabel("")
+	if expected := []string{" c1", " ^2", " c2"}; !reflect.DeepEqual(n.Annotations, expected) {
+		t.Fatalf("expect %q but got %q", expected, n.Annotations)
+	}
+	n.SetRefLabel("")
+	n.SetTypeLabel("string")
+	if expected := []string{" c1", " c2", "<string>"}; !reflect.DeepEqual(n.Annotations, expected) {
+		t.Fatalf("expect %q but got %q", expected, n.Annotations)
+	}
+	m := Node{Value: "b", Annotations: []string{" ^1"}}
+	m.SetRefLabel("")
+	if m.Annotations != nil {
+		t.Fatalf("expect nil but got %q", m.Annotations)
+	}
+}
+
+func TestCommentRoundTrip(t *testing.T) {
+	list, err := Parse(strings.NewReader(`
+# reviewed
+a:
+	# keep me
+	1
+b:
+	# ^old
+	2
+c:
+	^old
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	list[0].List[0].Value = "10"
+	list[0].List = append(List{{Value: "0"}}, list[0].List...)
+	list[1].List[0].SetRefLabel("1")
+	list[2].List[0].Value = "1"
+	expected := `# reviewed
+a:
+	0
+	# keep me
+	10
+b:
+	# ^1
+	2
+c:
+	^1`
+	if s := list.String(); s != expected {
+		t.Fatalf("expect \n%s\nbut got \n%s", expected, s)
+	}
+	if _, err := ResolveRefs(list); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -14,7 +14,7 @@ func (list List) String() string {
 
 func (list List) Marshal(w io.Writer, prefix, indent string) error {
 	ew := newErrWriter(w)
-	list.marshal(&ew, "", "\t")
+	list.marshal(&ew, prefix, indent)
 	ew.flush()
 	return ew.err
 }
@@ -35,7 +35,7 @@ func (n *Node) marshal(w *errWriter, prefix, indent string) {
 		w.writeString(a)
 		w.writeByte('\n')
 	}
-	if n.Value != "" {
+	if n.Value != "" || n.IsReference {
 		w.writeString(prefix)
 		if n.IsReference {
 			w.writeByte('^')
@@ -1,7 +1,6 @@
 package core
 
 import (
-	"bufio"
 	"errors"
 	"io"
 )
@@ -13,19 +12,20 @@ var (
 
 func Parse(reader io.Reader) (List, error) {
 	s := newParseStack()
-	scanner := NewScanner(bufio.NewReader(reader))
+	scanner := NewScanner(reader)
 	var a []string
+	var aLines []int
 	for scanner.Scan() {
 		tok := scanner.Token()
 		switch tok.Type {
 		case LineValue:
-			s.top().add(Node{Value: tok.Content, Annotations: a})
-			a = nil
+			s.top().add(Node{Value: tok.Content, Annotations: a, Line: tok.Line, AnnotationLines: aLines})
+			a, aLines = nil, nil
 		case Reference:
-			s.top().add(Node{Value: tok.Content, IsReference: true, Annotations: a})
-			a = nil
+			s.top().add(Node{Value: tok.Content, IsReference: true, Annotations: a, Line: tok.Line, AnnotationLines: aLines})
+			a, aLines = nil, nil
 		case Annotation:
-			a = append(a, tok.Content)
+			a, aLines = append(a, tok.Content), append(aLines, tok.Line)
 		case Indent:
 			if len(a) > 0 {
 				return nil, errAnnotationWithoutNode
@@ -1,6 +1,7 @@
 package core
 
 import (
+	"bytes"
 	"reflect"
 	"strings"
 	"testing"
@@ -44,3 +45,36 @@ a
 		}
 	}
 }
+
+func FuzzParse(f *testing.F) {
+	for _, testcase := range typeTestCases {
+		f.Add(testcase.s)
+	}
+	f.Fuzz(func(t *testing.T, s string) {
+		list, err := Parse(strings.NewReader(s))
+		if err != nil {
+			return
+		}
+		var buf bytes.Buffer
+		if err := list.Marshal(&buf, "", "\t"); err != nil {
+			t.Fatal(err)
+		}
+		again, err := Parse(bytes.NewReader(buf.Bytes()))
+		if err != nil {
+			t.Fatalf("parse %q marshaled from %q: %v", buf.String(), s, err)
+		}
+		if !reflect.DeepEqual(clearLines(again), clearLines(list)) {
+			t.Fatalf("parse %q marshaled from %q: expect\n%#v\nbut got\n%#v", buf.String(), s, list, again)
+		}
+	})
+}
+
+// clearLines clears the line numbers of the nodes in list, which are not
+// kept by a round trip.
+func clearLines(list List) List {
+	for i := range list {
+		list[i].Line, list[i].AnnotationLines = 0, nil
+		clearLines(list[i].List)
+	}
+	return list
+}
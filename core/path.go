@@ -0,0 +1,257 @@
+package core
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+var errInvalidPath = errors.New("invalid path")
+
+type stepType int
+
+const (
+	keyStep stepType = iota
+	indexStep
+	anyKeyStep
+	anyIndexStep
+)
+
+type step struct {
+	typ       stepType
+	key       string
+	index     int
+	recursive bool
+}
+
+// Lookup returns the node at path, e.g. "^users[2]address:city", where a key
+// segment matches a node whose value is the key suffixed by ":" and an index
+// segment "[n]" matches the n-th node of a list. Keys may be separated by an
+// optional ":". It returns nil if the path is invalid or nothing matches.
+func (l List) Lookup(path string) *Node {
+	steps, err := parsePath(path)
+	if err != nil {
+		return nil
+	}
+	for _, st := range steps {
+		if st.recursive || st.typ == anyKeyStep || st.typ == anyIndexStep {
+			return nil
+		}
+	}
+	nodes := l.selectSteps(steps)
+	if len(nodes) == 0 {
+		return nil
+	}
+	return nodes[0]
+}
+
+// Select returns all the nodes matched by query in document order. Besides
+// the segments accepted by Lookup, a query may contain wildcards, "*" for any
+// key and "[*]" for any node of a list, and the recursive descent "..", which
+// applies the next segment to every descendant, e.g. "^..city" or
+// "^users[*]name".
+func (l List) Select(query string) ([]*Node, error) {
+	steps, err := parsePath(query)
+	if err != nil {
+		return nil, err
+	}
+	return l.selectSteps(steps), nil
+}
+
+func (l List) selectSteps(steps []step) []*Node {
+	nodes := []*Node{&Node{List: l}}
+	for _, st := range steps {
+		if st.recursive {
+			nodes = descendantsOrSelf(nodes)
+		}
+		var next []*Node
+		for _, node := range nodes {
+			next = st.match(node.List, next)
+		}
+		nodes = next
+	}
+	if len(steps) == 0 {
+		return nil
+	}
+	return nodes
+}
+
+// JoinKey returns the path of the value of key in the map at path, e.g.
+// "^users[2]address" for the key "address" at "^users[2]". key is quoted if
+// it would otherwise be read as other segments.
+func JoinKey(path, key string) string {
+	if strings.ContainsAny(key, ":[]\"") || strings.Contains(key, "..") || !IsValue(key) {
+		key = Quote(key)
+	}
+	if path == "^" || strings.HasSuffix(path, "]") {
+		return path + key
+	}
+	return path + ":" + key
+}
+
+// JoinIndex returns the path of the i-th node of the list at path.
+func JoinIndex(path string, i int) string {
+	return path + "[" + strconv.Itoa(i) + "]"
+}
+
+func (st step) match(l List, nodes []*Node) []*Node {
+	switch st.typ {
+	case keyStep:
+		for i := range l {
+			if l[i].hasKey(st.key) {
+				nodes = append(nodes, &l[i])
+			}
+		}
+	case anyKeyStep:
+		for i := range l {
+			if l[i].IsKey() {
+				nodes = append(nodes, &l[i])
+			}
+		}
+	case indexStep:
+		if st.index < len(l) {
+			nodes = append(nodes, &l[st.index])
+		}
+	case anyIndexStep:
+		for i := range l {
+			nodes = append(nodes, &l[i])
+		}
+	}
+	return nodes
+}
+
+// IsKey reports whether n is a key node "key:".
+func (n *Node) IsKey() bool {
+	return !n.IsReference && strings.HasSuffix(n.Value, ":")
+}
+
+func (n *Node) hasKey(key string) bool {
+	return !n.IsReference && len(n.Value) == len(key)+1 &&
+		strings.HasPrefix(n.Value, key) && strings.HasSuffix(n.Value, ":")
+}
+
+func descendantsOrSelf(nodes []*Node) []*Node {
+	var all []*Node
+	var walk func(n *Node)
+	walk = func(n *Node) {
+		all = append(all, n)
+		for i := range n.List {
+			walk(&n.List[i])
+		}
+	}
+	for _, n := range nodes {
+		walk(n)
+	}
+	return all
+}
+
+func parsePath(path string) ([]step, error) {
+	if !strings.HasPrefix(path, "^") {
+		return nil, errInvalidPath
+	}
+	s := path[1:]
+	var steps []step
+	recursive := false
+	for len(s) > 0 {
+		var st step
+		switch {
+		case strings.HasPrefix(s, ".."):
+			if recursive {
+				return nil, errInvalidPath
+			}
+			recursive = true
+			s = s[2:]
+			continue
+		case s[0] == ':':
+			if len(steps) == 0 || steps[len(steps)-1].typ != keyStep || recursive {
+				return nil, errInvalidPath
+			}
+			s = s[1:]
+			continue
+		case s[0] == '[':
+			end := strings.IndexByte(s, ']')
+			if end < 0 {
+				return nil, errInvalidPath
+			}
+			if idx := s[1:end]; idx == "*" {
+				st.typ = anyIndexStep
+			} else {
+				i, err := parseIndex(idx)
+				if err != nil {
+					return nil, err
+				}
+				st.typ, st.index = indexStep, i
+			}
+			s = s[end+1:]
+		case s[0] == '"':
+			end := quoteEnd(s)
+			if end < 0 {
+				return nil, errInvalidPath
+			}
+			st.typ, st.key = keyStep, s[:end]
+			s = s[end:]
+		default:
+			end := keyEnd(s)
+			if end == 0 {
+				return nil, errInvalidPath
+			}
+			if key := s[:end]; key == "*" {
+				st.typ = anyKeyStep
+			} else {
+				st.typ, st.key = keyStep, key
+			}
+			s = s[end:]
+		}
+		st.recursive, recursive = recursive, false
+		steps = append(steps, st)
+	}
+	if recursive {
+		return nil, errInvalidPath
+	}
+	return steps, nil
+}
+
+// parseIndex parses array_index, i.e. decimals without sign or leading zeros.
+func parseIndex(s string) (int, error) {
+	if s == "" || (len(s) > 1 && s[0] == '0') {
+		return 0, errInvalidPath
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return 0, errInvalidPath
+		}
+	}
+	i, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, errInvalidPath
+	}
+	return i, nil
+}
+
+// quoteEnd returns the position right after the closing quote of the
+// interpreted string at the start of s, or -1 if it is not closed.
+func quoteEnd(s string) int {
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '"':
+			return i + 1
+		}
+	}
+	return -1
+}
+
+func keyEnd(s string) int {
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case ':', '[':
+			return i
+		case '.':
+			if strings.HasPrefix(s[i:], "..") {
+				return i
+			}
+		}
+	}
+	return len(s)
+}
@@ -0,0 +1,128 @@
+package core
+
+import (
+	"strings"
+	"testing"
+)
+
+const pathTestDoc = `
+users:
+	_
+		name:
+			a
+	_
+		name:
+			b
+	_
+		name:
+			c
+		address:
+			city:
+				x
+			"zip code":
+				1
+version:
+	2
+`
+
+func TestLookup(t *testing.T) {
+	list, err := Parse(strings.NewReader(pathTestDoc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, testcase := range []struct {
+		path     string
+		expected string
+	}{
+		{"^version", "version:"},
+		{"^version:", "version:"},
+		{"^version[0]", "2"},
+		{"^users[0]", "_"},
+		{"^users[2]address:city", "city:"},
+		{"^users[2]address:city[0]", "x"},
+		{"^users:[2]address:city:[0]", "x"},
+		{`^users[2]address:"zip code"[0]`, "1"},
+		{"^users[1]name[0]", "b"},
+	} {
+		node := list.Lookup(testcase.path)
+		if node == nil {
+			t.Fatalf("testcase %d: %s not found", i, testcase.path)
+		}
+		if node.Value != testcase.expected {
+			t.Fatalf("testcase %d: expect %s but got %s", i, testcase.expected, node.Value)
+		}
+	}
+	for i, path := range []string{
+		"",
+		"^",
+		"users",
+		"^users[3]",
+		"^users[01]",
+		"^users[-1]",
+		"^users[1",
+		"^missing",
+		"^users[*]",
+		"^..name",
+		"^*",
+	} {
+		if node := list.Lookup(path); node != nil {
+			t.Fatalf("testcase %d: expect nil but got %v", i, node.Value)
+		}
+	}
+}
+
+func TestSelect(t *testing.T) {
+	list, err := Parse(strings.NewReader(pathTestDoc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, testcase := range []struct {
+		query    string
+		expected string
+	}{
+		{"^users[*]name[0]", "a b c"},
+		{"^..name[0]", "a b c"},
+		{"^..city[*]", "x"},
+		{"^*", "users: version:"},
+		{"^users[2]*", "name: address:"},
+		{"^..[0]", "users: _ name: a name: b name: c city: x 1 2"},
+		{"^nothing", ""},
+	} {
+		nodes, err := list.Select(testcase.query)
+		if err != nil {
+			t.Fatalf("testcase %d: %v", i, err)
+		}
+		values := make([]string, len(nodes))
+		for j, node := range nodes {
+			values[j] = node.Value
+		}
+		if actual := strings.Join(values, " "); actual != testcase.expected {
+			t.Fatalf("testcase %d: expect %q but got %q", i, testcase.expected, actual)
+		}
+	}
+	for i, query := range []string{"x", "^[a]", "^..", "^....a", "^[0]:"} {
+		if _, err := list.Select(query); err == nil {
+			t.Fatalf("testcase %d: expect error but got nil", i)
+		}
+	}
+}
+
+func TestJoinKey(t *testing.T) {
+	for i, testcase := range []struct {
+		path     string
+		expected string
+	}{
+		{JoinKey("^", "users"), "^users"},
+		{JoinKey(JoinIndex(JoinKey("^", "users"), 2), "address"), "^users[2]address"},
+		{JoinKey(JoinKey("^", "address"), "city"), "^address:city"},
+		{JoinIndex("^", 0), "^[0]"},
+		{JoinKey("^", "a:b"), `^"a:b"`},
+		{JoinKey("^", "a[0]"), `^"a[0]"`},
+		{JoinKey("^", "a..b"), `^"a..b"`},
+		{JoinKey("^", ""), `^""`},
+	} {
+		if testcase.path != testcase.expected {
+			t.Fatalf("testcase %d: expect %s but got %s", i, testcase.expected, testcase.path)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package core
+
+import (
+	"fmt"
+	"strings"
+)
+
+// RefGraph links the reference nodes of a list to their targets.
+type RefGraph struct {
+	// Labels maps a label id to the node annotated with "# ^id".
+	Labels map[string]*Node
+	// Targets maps a reference node to the node it refers to. The target of
+	// the root reference "^" is nil.
+	Targets map[*Node]*Node
+}
+
+// Target returns the node that ref refers to.
+func (g *RefGraph) Target(ref *Node) (*Node, bool) {
+	target, ok := g.Targets[ref]
+	return target, ok
+}
+
+// RefError is an error of a label or reference at a line.
+type RefError struct {
+	Line int
+	Msg  string
+}
+
+func (e *RefError) Error() string {
+	return fmt.Sprintf("line %d: %s", e.Line, e.Msg)
+}
+
+// RefErrors is a list of RefError.
+type RefErrors []*RefError
+
+func (es RefErrors) Error() string {
+	ss := make([]string, len(es))
+	for i, e := range es {
+		ss[i] = e.Error()
+	}
+	return strings.Join(ss, "\n")
+}
+
+// ResolveRefs resolves every reference node in list either to the node
+// labeled with the same id, or to the node at the path it represents. A graph
+// of all the resolved references is returned, together with RefErrors for
+// duplicate labels and dangling references if any.
+func ResolveRefs(list List) (*RefGraph, error) {
+	g := &RefGraph{
+		Labels:  make(map[string]*Node),
+		Targets: make(map[*Node]*Node),
+	}
+	var errs RefErrors
+	var refs []*Node
+	walk(list, func(n *Node) {
+		if n.IsReference {
+			refs = append(refs, n)
+		}
+		for i, a := range n.Annotations {
+			kind, id := ClassifyAnnotation(a)
+			if kind != LabelAnnotation {
+				continue
+			}
+			if _, dup := g.Labels[id]; dup {
+				errs = append(errs, &RefError{n.annotationLine(i), fmt.Sprintf("duplicate label ^%s", id)})
+				continue
+			}
+			g.Labels[id] = n
+		}
+	})
+	for _, ref := range refs {
+		if target, ok := g.Labels[ref.Value]; ok {
+			g.Targets[ref] = target
+			continue
+		}
+		if ref.Value == "" {
+			g.Targets[ref] = nil
+			continue
+		}
+		target := list.Lookup("^" + ref.Value)
+		if target == nil {
+			errs = append(errs, &RefError{ref.Line, fmt.Sprintf("dangling reference ^%s", ref.Value)})
+			continue
+		}
+		g.Targets[ref] = target
+	}
+	if len(errs) > 0 {
+		return g, errs
+	}
+	return g, nil
+}
+
+// annotationLine returns the line of the annotation i of n, or that of n if
+// the annotation is not parsed.
+func (n *Node) annotationLine(i int) int {
+	if i < len(n.AnnotationLines) {
+		return n.AnnotationLines[i]
+	}
+	return n.Line
+}
+
+func walk(list List, visit func(n *Node)) {
+	for i := range list {
+		visit(&list[i])
+		walk(list[i].List, visit)
+	}
+}
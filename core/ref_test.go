@@ -0,0 +1,64 @@
+package core
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestResolveRefs(t *testing.T) {
+	list, err := Parse(strings.NewReader(`
+a:
+	# ^1
+	x
+b:
+	^1
+c:
+	^a[0]
+d:
+	^
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	g, err := ResolveRefs(list)
+	if err != nil {
+		t.Fatal(err)
+	}
+	x := &list[0].List[0]
+	if g.Labels["1"] != x {
+		t.Fatalf("expect label 1 on x but got %v", g.Labels["1"])
+	}
+	for i, ref := range []*Node{&list[1].List[0], &list[2].List[0]} {
+		if target, ok := g.Target(ref); !ok || target != x {
+			t.Fatalf("testcase %d: expect target x but got %v", i, target)
+		}
+	}
+	if target, ok := g.Target(&list[3].List[0]); !ok || target != nil {
+		t.Fatalf("expect root target but got %v", target)
+	}
+}
+
+func TestResolveRefsError(t *testing.T) {
+	for i, testcase := range []struct {
+		s        string
+		expected string
+	}{
+		{"^1", "line 1: dangling reference ^1"},
+		{"a\n^b[0]", "line 2: dangling reference ^b[0]"},
+		{"#^1\na\n# ^1\nb", "line 3: duplicate label ^1"},
+		{"#^1\na\n#^1\nb\n^2", "line 3: duplicate label ^1\nline 5: dangling reference ^2"},
+		{"#^1\na\n# ^1\n# a comment\nb", "line 3: duplicate label ^1"},
+	} {
+		list, err := Parse(strings.NewReader(testcase.s))
+		if err != nil {
+			t.Fatalf("testcase %d: %v", i, err)
+		}
+		_, err = ResolveRefs(list)
+		if err == nil {
+			t.Fatalf("testcase %d: expect error but got nil", i)
+		}
+		if err.Error() != testcase.expected {
+			t.Fatalf("testcase %d: expect\n%s\ngot\n%s", i, testcase.expected, err.Error())
+		}
+	}
+}
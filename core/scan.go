@@ -1,10 +1,11 @@
 package core
 
 import (
+	"bufio"
 	"errors"
 	"io"
-	"strings"
-	"unicode"
+	"sync"
+	"unicode/utf8"
 )
 
 var (
@@ -28,69 +29,101 @@ const (
 type Token struct {
 	Type    TokenType
 	Content string
+	Line    int
 }
 
+// Scanner scans the tokens of a TEFF stream line by line. Lines are read
+// from the buffer of a bufio.Reader and validated in place, so that only the
+// content of tokens is copied.
 type Scanner struct {
-	reader
+	r      *bufio.Reader
+	pooled bool
+	skipLF bool
+	line   int
 	indenter
-	tokenQueue
-	err error
+	toks []Token
+	head int
+	err  error
+}
+
+var readerPool = sync.Pool{
+	New: func() interface{} { return bufio.NewReader(nil) },
 }
 
-func NewScanner(r io.RuneScanner) *Scanner {
-	return &Scanner{
-		reader: reader{r: r},
+func NewScanner(r io.Reader) *Scanner {
+	s := &Scanner{
+		line: 1,
 		indenter: indenter{
 			indents: []string{""},
 		},
-		tokenQueue: tokenQueue{
-			toks: []Token{Token{Type: _SOF}},
-		},
+		toks: []Token{{Type: _SOF}},
+	}
+	if br, ok := r.(*bufio.Reader); ok {
+		s.r = br
+	} else {
+		s.r = readerPool.Get().(*bufio.Reader)
+		s.r.Reset(r)
+		s.pooled = true
 	}
+	return s
 }
 
 func (s *Scanner) Scan() bool {
 	s.popTok()
-	if s.tokCount() > 0 {
-		return true
+	for s.tokCount() == 0 && s.err == nil {
+		s.scanLine()
 	}
-	if s.err != nil {
-		return false
-	}
-	s.scanLine()
-	if s.err == io.EOF {
-		for i := 0; i < s.eofUnindentLevel(); i++ {
-			s.pushTok(Token{Type: Unindent})
-		}
-		s.pushTok(Token{Type: EOF})
+	if s.tokCount() == 0 && s.pooled {
+		s.r.Reset(nil)
+		readerPool.Put(s.r)
+		s.r, s.pooled = nil, false
 	}
 	return s.tokCount() > 0
 }
 
+// scanLine scans a line and pushes its tokens, and the tokens at EOF.
 func (s *Scanner) scanLine() {
-	var indent string
-	indent, s.err = s.readValidIndent()
-	if s.err != nil {
+	line, err := s.readLine()
+	if err != nil && err != io.EOF {
+		s.err = err
 		return
 	}
-	indentType, n, err := s.indentLevel(indent)
-	if err != nil {
-		s.err = err
+	if !validLine(line) {
+		s.err = errInvalidCodePoint
 		return
 	}
-	for i := 0; i < n; i++ {
-		s.pushTok(Token{Type: indentType})
+	i := 0
+	for i < len(line) && (line[i] == ' ' || line[i] == '\t') {
+		i++
 	}
-	var line string
-	line, s.err = s.readLine()
-	switch line[0] {
-	case '#':
-		s.pushTok(Token{Type: Annotation, Content: line[1:]})
-	case '^':
-		s.pushTok(Token{Type: Reference, Content: line[1:]})
-	default:
-		s.pushTok(Token{Type: LineValue, Content: line})
+	if i < len(line) {
+		indentType, n, err := s.indentLevel(line[:i])
+		if err != nil {
+			s.err = err
+			return
+		}
+		for j := 0; j < n; j++ {
+			s.pushTok(Token{Type: indentType, Line: s.line})
+		}
+		switch line[i] {
+		case '#':
+			s.pushTok(Token{Type: Annotation, Content: string(line[i+1:]), Line: s.line})
+		case '^':
+			s.pushTok(Token{Type: Reference, Content: string(line[i+1:]), Line: s.line})
+		default:
+			s.pushTok(Token{Type: LineValue, Content: string(line[i:]), Line: s.line})
+		}
 	}
+	if err == io.EOF {
+		n := s.eofUnindentLevel()
+		for j := 0; j < n; j++ {
+			s.pushTok(Token{Type: Unindent, Line: s.line})
+		}
+		s.pushTok(Token{Type: EOF, Line: s.line})
+		s.err = io.EOF
+		return
+	}
+	s.line++
 }
 
 func (s *Scanner) Err() error {
@@ -100,102 +133,81 @@ func (s *Scanner) Err() error {
 	return s.err
 }
 
-type reader struct {
-	r   io.RuneScanner
-	ch  rune
-	err error
-}
-
-func (s *reader) readLine() (string, error) {
-	rs := []rune{}
-	for s.next() {
-		switch s.ch {
-		case '\r', '\n':
-			s.prev()
-			return string(rs), nil
-		}
-		rs = append(rs, s.ch)
-	}
-	return string(rs), s.err
-}
-
-// readValidIndent reads an indent that not ends with line breaks, and skips
-// a line when necessary.
-func (s *reader) readValidIndent() (string, error) {
+// readLine reads a line ended by "\n", "\r", "\r\n" or EOF and returns it
+// without the line break. The line is valid until the next read.
+func (s *Scanner) readLine() ([]byte, error) {
+	var long []byte
 	for {
-		indent := s.indentSpaces()
-		if s.err != nil {
-			return indent, s.err
+		if _, err := s.r.Peek(1); err != nil {
+			return long, err
 		}
-		if !s.skipLineBreaks() {
-			return indent, s.err
+		buf, _ := s.r.Peek(s.r.Buffered())
+		if s.skipLF {
+			s.skipLF = false
+			if buf[0] == '\n' {
+				s.r.Discard(1)
+				continue
+			}
 		}
-	}
-}
-func (s *reader) skipLineBreaks() (isLineBreak bool) {
-	for s.next() {
-		switch s.ch {
-		case '\r', '\n':
-			isLineBreak = true
-		default:
-			s.prev()
-			return
+		for i, c := range buf {
+			if c != '\n' && c != '\r' {
+				continue
+			}
+			n := i + 1
+			if c == '\r' {
+				if n < len(buf) {
+					if buf[n] == '\n' {
+						n++
+					}
+				} else {
+					s.skipLF = true
+				}
+			}
+			s.r.Discard(n)
+			if long != nil {
+				return append(long, buf[:i]...), nil
+			}
+			return buf[:i], nil
 		}
+		// a line longer than the buffer
+		long = append(long, buf...)
+		s.r.Discard(len(buf))
 	}
-	return
-}
-func (s *reader) indentSpaces() string {
-	rs := []rune{}
-	for s.next() {
-		switch s.ch {
-		case ' ', '\t':
-		default:
-			s.prev()
-			return string(rs)
-		}
-		rs = append(rs, s.ch)
-	}
-	return string(rs)
 }
 
-func (s *reader) next() bool {
-	s.ch, _, s.err = s.r.ReadRune()
-	if s.err != nil {
-		return false
-	}
-	switch s.ch {
-	case '\t', ' ', '\r', '\n':
-	case unicode.ReplacementChar:
-		s.err = errInvalidCodePoint
-		return false
-	default:
-		if '\x00' <= s.ch && s.ch <= '\x19' {
-			s.err = errInvalidCodePoint
+// validLine reports whether line consists of valid code points only.
+func validLine(line []byte) bool {
+	for i := 0; i < len(line); {
+		if c := line[i]; c < utf8.RuneSelf {
+			if c < ' ' && c != '\t' {
+				return false
+			}
+			i++
+			continue
+		}
+		r, size := utf8.DecodeRune(line[i:])
+		if r == utf8.RuneError {
 			return false
 		}
+		i += size
 	}
 	return true
 }
 
-func (s *reader) prev() bool {
-	s.err = s.r.UnreadRune()
-	return s.err == nil
-}
-
 type indenter struct {
 	indents []string
 }
 
-func (s *indenter) indentLevel(indent string) (TokenType, int, error) {
+func (s *indenter) indentLevel(indent []byte) (TokenType, int, error) {
 	top := s.indents[len(s.indents)-1]
-	if indent == top {
+	if string(indent) == top {
 		return 0, 0, nil
-	} else if strings.HasPrefix(indent, top) {
-		s.indents = append(s.indents, indent)
+	} else if len(indent) > len(top) && string(indent[:len(top)]) == top {
+		s.indents = append(s.indents, string(indent))
 		return Indent, 1, nil
 	}
 	for i := 1; i < len(s.indents); i++ {
-		if indent == s.indents[len(s.indents)-i-1] {
+		if string(indent) == s.indents[len(s.indents)-i-1] {
 			s.indents = s.indents[:len(s.indents)-i]
 			return Unindent, i, nil
 		}
@@ -212,22 +224,21 @@ func (s *indenter) eofUnindentLevel() int {
 	return 0
 }
 
-type tokenQueue struct {
-	toks []Token
+func (s *Scanner) Token() Token {
+	return s.toks[s.head]
 }
 
-func (s *tokenQueue) Token() Token {
-	return s.toks[0]
-}
-
-func (s *tokenQueue) pushTok(tok Token) {
+func (s *Scanner) pushTok(tok Token) {
 	s.toks = append(s.toks, tok)
 }
 
-func (s *tokenQueue) popTok() {
-	s.toks = s.toks[1:]
+func (s *Scanner) popTok() {
+	s.head++
+	if s.head == len(s.toks) {
+		s.toks, s.head = s.toks[:0], 0
+	}
 }
 
-func (s *tokenQueue) tokCount() int {
-	return len(s.toks)
+func (s *Scanner) tokCount() int {
+	return len(s.toks) - s.head
 }
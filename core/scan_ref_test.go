@@ -0,0 +1,339 @@
+package core
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"unicode"
+	"unicode/utf8"
+)
+
+// refScanner is the rune based scanner that Scanner replaced. It is kept as
+// the reference that Scanner is checked and benchmarked against.
+type refScanner struct {
+	refReader
+	refIndenter
+	refTokenQueue
+	err error
+}
+
+func newRefScanner(r io.RuneScanner) *refScanner {
+	return &refScanner{
+		refReader: refReader{r: r, line: 1},
+		refIndenter: refIndenter{
+			indents: []string{""},
+		},
+		refTokenQueue: refTokenQueue{
+			toks: []Token{Token{Type: _SOF}},
+		},
+	}
+}
+
+func (s *refScanner) Scan() bool {
+	s.popTok()
+	if s.tokCount() > 0 {
+		return true
+	}
+	if s.err != nil {
+		return false
+	}
+	s.scanLine()
+	if s.err == io.EOF {
+		n := s.eofUnindentLevel()
+		for i := 0; i < n; i++ {
+			s.pushTok(Token{Type: Unindent, Line: s.line})
+		}
+		s.pushTok(Token{Type: EOF, Line: s.line})
+	}
+	return s.tokCount() > 0
+}
+
+func (s *refScanner) scanLine() {
+	var indent string
+	indent, s.err = s.readValidIndent()
+	if s.err != nil {
+		return
+	}
+	indentType, n, err := s.indentLevel(indent)
+	if err != nil {
+		s.err = err
+		return
+	}
+	for i := 0; i < n; i++ {
+		s.pushTok(Token{Type: indentType, Line: s.line})
+	}
+	var line string
+	line, s.err = s.readLine()
+	switch line[0] {
+	case '#':
+		s.pushTok(Token{Type: Annotation, Content: line[1:], Line: s.line})
+	case '^':
+		s.pushTok(Token{Type: Reference, Content: line[1:], Line: s.line})
+	default:
+		s.pushTok(Token{Type: LineValue, Content: line, Line: s.line})
+	}
+}
+
+func (s *refScanner) Err() error {
+	if s.err == io.EOF {
+		return nil
+	}
+	return s.err
+}
+
+type refReader struct {
+	r    io.RuneScanner
+	ch   rune
+	err  error
+	line int
+}
+
+func (s *refReader) readLine() (string, error) {
+	rs := []rune{}
+	for s.next() {
+		switch s.ch {
+		case '\r', '\n':
+			s.prev()
+			return string(rs), nil
+		}
+		rs = append(rs, s.ch)
+	}
+	return string(rs), s.err
+}
+
+// readValidIndent reads an indent that not ends with line breaks, and skips
+// a line when necessary.
+func (s *refReader) readValidIndent() (string, error) {
+	for {
+		indent := s.indentSpaces()
+		if s.err != nil {
+			return indent, s.err
+		}
+		if !s.skipLineBreaks() {
+			return indent, s.err
+		}
+	}
+}
+func (s *refReader) skipLineBreaks() (isLineBreak bool) {
+	var last rune
+	for s.next() {
+		switch s.ch {
+		case '\r', '\n':
+			if s.ch == '\r' || last != '\r' {
+				s.line++
+			}
+			last = s.ch
+			isLineBreak = true
+		default:
+			s.prev()
+			return
+		}
+	}
+	return
+}
+func (s *refReader) indentSpaces() string {
+	rs := []rune{}
+	for s.next() {
+		switch s.ch {
+		case ' ', '\t':
+		default:
+			s.prev()
+			return string(rs)
+		}
+		rs = append(rs, s.ch)
+	}
+	return string(rs)
+}
+
+func (s *refReader) next() bool {
+	s.ch, _, s.err = s.r.ReadRune()
+	if s.err != nil {
+		return false
+	}
+	switch s.ch {
+	case '\t', ' ', '\r', '\n':
+	case unicode.ReplacementChar:
+		s.err = errInvalidCodePoint
+		return false
+	default:
+		if '\x00' <= s.ch && s.ch <= '\x19' {
+			s.err = errInvalidCodePoint
+			return false
+		}
+	}
+	return true
+}
+
+func (s *refReader) prev() bool {
+	s.err = s.r.UnreadRune()
+	return s.err == nil
+}
+
+type refIndenter struct {
+	indents []string
+}
+
+func (s *refIndenter) indentLevel(indent string) (TokenType, int, error) {
+	top := s.indents[len(s.indents)-1]
+	if indent == top {
+		return 0, 0, nil
+	} else if strings.HasPrefix(indent, top) {
+		s.indents = append(s.indents, indent)
+		return Indent, 1, nil
+	}
+	for i := 1; i < len(s.indents); i++ {
+		if indent == s.indents[len(s.indents)-i-1] {
+			s.indents = s.indents[:len(s.indents)-i]
+			return Unindent, i, nil
+		}
+	}
+	return 0, 0, errMismatchIndent
+}
+
+func (s *refIndenter) eofUnindentLevel() int {
+	if len(s.indents) > 1 {
+		n := len(s.indents) - 1
+		s.indents = s.indents[:1]
+		return n
+	}
+	return 0
+}
+
+type refTokenQueue struct {
+	toks []Token
+}
+
+func (s *refTokenQueue) Token() Token {
+	return s.toks[0]
+}
+
+func (s *refTokenQueue) pushTok(tok Token) {
+	s.toks = append(s.toks, tok)
+}
+
+func (s *refTokenQueue) popTok() {
+	s.toks = s.toks[1:]
+}
+
+func (s *refTokenQueue) tokCount() int {
+	return len(s.toks)
+}
+
+var equivalenceCases = []string{
+	"",
+	"x\n\ty\n\t\tz\n\t\t\tw\nv",
+	"a\r\n\tb\r\r\n\t\tc\n\r\n  d\r",
+	"#a\n^b\n\t#c\n\t^d\n\te f  \n \n\t\n",
+	"x\n\ty\n x",
+	"x\n\ty\n\t\tz\n\ty\n\t\t z\n",
+	"日本\n\t語\n\x7f",
+	strings.Repeat("a", 5000) + "\r\n\t" + strings.Repeat("b", 20) + "\r" + strings.Repeat("\r\n", 10),
+	strings.Repeat("x\r\n\ty\r", 20),
+}
+
+func TestScanEquivalence(t *testing.T) {
+	for i, testcase := range equivalenceCases {
+		if err := checkScanEquivalence(testcase); err != nil {
+			t.Fatalf("testcase %d: %v", i, err)
+		}
+	}
+}
+
+func FuzzScan(f *testing.F) {
+	for _, testcase := range equivalenceCases {
+		f.Add(testcase)
+	}
+	f.Fuzz(func(t *testing.T, s string) {
+		if err := checkScanEquivalence(s); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+// checkScanEquivalence checks that Scanner scans the same tokens as the
+// reference scanner from a valid input, or returns an error from an invalid
+// one, with both a default and a minimal read buffer.
+func checkScanEquivalence(s string) error {
+	expected, expectedErr := refScanTokens(s)
+	for _, size := range []int{4096, 16} {
+		toks, err := scanTokens(bufio.NewReaderSize(strings.NewReader(s), size))
+		if !validInput(s) {
+			if err == nil {
+				return fmt.Errorf("expect error for invalid input %q", s)
+			}
+			continue
+		}
+		if err != expectedErr || !reflect.DeepEqual(toks, expected) {
+			return fmt.Errorf("input %q, buffer %d: expect %v, %v but got %v, %v", s, size, expected, expectedErr, toks, err)
+		}
+	}
+	return nil
+}
+
+func validInput(s string) bool {
+	for _, r := range s {
+		if r == utf8.RuneError || r < ' ' && r != '\t' && r != '\r' && r != '\n' {
+			return false
+		}
+	}
+	return true
+}
+
+func scanTokens(r io.Reader) ([]Token, error) {
+	var toks []Token
+	s := NewScanner(r)
+	for s.Scan() {
+		toks = append(toks, s.Token())
+	}
+	return toks, s.Err()
+}
+
+func refScanTokens(str string) ([]Token, error) {
+	var toks []Token
+	s := newRefScanner(bufio.NewReader(strings.NewReader(str)))
+	for s.Scan() {
+		toks = append(toks, s.Token())
+	}
+	return toks, s.Err()
+}
+
+func benchmarkDoc() string {
+	var b strings.Builder
+	for b.Len() < 1<<20 {
+		b.WriteString("# a comment\nkey:\n\tvalue 日本語\n\t_\n\t\t\"quoted\\tstring\"\n\t\t^key[1]\n")
+	}
+	return b.String()
+}
+
+func BenchmarkScan(b *testing.B) {
+	doc := benchmarkDoc()
+	b.SetBytes(int64(len(doc)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		s := NewScanner(strings.NewReader(doc))
+		for s.Scan() {
+		}
+		if s.Err() != nil {
+			b.Fatal(s.Err())
+		}
+	}
+}
+
+func BenchmarkScanReference(b *testing.B) {
+	doc := benchmarkDoc()
+	b.SetBytes(int64(len(doc)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		s := newRefScanner(bufio.NewReader(strings.NewReader(doc)))
+		for s.Scan() {
+		}
+		if s.Err() != nil {
+			b.Fatal(s.Err())
+		}
+	}
+}
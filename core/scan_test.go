@@ -65,6 +65,7 @@ func TestScan(t *testing.T) {
 		{"1\n\t2\n\t\t3\n\t\t\t4\n5", "<1:s> <in> <2:s> <in> <3:s> <in> <4:s> <un> <un> <un> <5:s> <eof>"},
 
 		{"\tx\n\t\ty\nz", "<in> <x:s> <in> <y:s> <un> <un> <z:s> <eof>"},
+		{"x\n\ty\n\t\tz", "<x:s> <in> <y:s> <in> <z:s> <un> <un> <eof>"},
 		{"\t#x\n#y\ny", "<in> <x:a> <un> <y:a> <y:s> <eof>"},
 	} {
 		toks, err := scanAll(testcase.s)
@@ -78,6 +79,30 @@ func TestScan(t *testing.T) {
 	}
 }
 
+func TestScanLine(t *testing.T) {
+	for i, testcase := range []struct {
+		s        string
+		expected []int
+	}{
+		{"a\nb", []int{1, 2, 2}},
+		{"a\r\nb", []int{1, 2, 2}},
+		{"a\n\rb", []int{1, 3, 3}},
+		{"\n\na\n  \n\tb\n", []int{3, 5, 5, 6, 6}},
+	} {
+		s := NewScanner(bufio.NewReader(strings.NewReader(testcase.s)))
+		var lines []int
+		for s.Scan() {
+			lines = append(lines, s.Token().Line)
+		}
+		if s.Err() != nil {
+			t.Fatalf("testcase %d: %v", i, s.Err())
+		}
+		if fmt.Sprint(lines) != fmt.Sprint(testcase.expected) {
+			t.Fatalf("testcase %d: expect %v got %v", i, testcase.expected, lines)
+		}
+	}
+}
+
 func TestMismatch(t *testing.T) {
 	_, err := scanAll("x\n\ty\n x")
 	if err == nil {
@@ -90,26 +115,35 @@ func TestInvalidChar(t *testing.T) {
 		"\x00",
 		"\x19",
 		"\xed\xa0",
+		"\x1f",
 	} {
 		s := NewScanner(bufio.NewReader(strings.NewReader(testcase)))
 		if s.Scan() != false || s.Err() == nil {
 			t.Fatalf("testcase %d: expect error for illegal character.", i)
 		}
 	}
+	for i, testcase := range []string{
+		"a\n\x01b",
+		"a\r\xff",
+		"a\n\tb\x7f\x1a",
+	} {
+		if _, err := scanAll(testcase); err != errInvalidCodePoint {
+			t.Fatalf("testcase %d: expect error %v but got %v", i, errInvalidCodePoint, err)
+		}
+	}
 }
 
 func TestReadError(t *testing.T) {
-	s := NewScanner(errRuneReader{})
+	s := NewScanner(errReader{})
 	if s.Scan() != false || s.Err() == nil {
 		t.Fatal("expect read error.")
 	}
 }
-func (errRuneReader) ReadRune() (rune, int, error) {
-	return 0, 0, errors.New("any error")
+func (errReader) Read([]byte) (int, error) {
+	return 0, errors.New("any error")
 }
-func (errRuneReader) UnreadRune() error { return nil }
 
-type errRuneReader struct{}
+type errReader struct{}
 
 func scanAll(testcase string) (toks []string, err error) {
 	s := NewScanner(bufio.NewReader(strings.NewReader(testcase)))
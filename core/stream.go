@@ -0,0 +1,121 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+var errUnexpectedToken = errors.New("syntax error, unexpected token")
+
+// SyntaxError is a syntax error at a line, returned by NodeReader.
+type SyntaxError struct {
+	Line int
+	Err  error
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("line %d: %v", e.Line, e.Err)
+}
+
+// NodeReader reads tokens or whole nodes from a TEFF stream one at a time, so
+// that a large list can be processed without holding all of it in memory.
+type NodeReader struct {
+	s      *Scanner
+	tok    Token
+	peeked bool
+	err    error
+}
+
+func NewNodeReader(r io.Reader) *NodeReader {
+	return &NodeReader{s: NewScanner(r)}
+}
+
+// Peek returns the next token without consuming it.
+func (r *NodeReader) Peek() (Token, error) {
+	if r.peeked || r.err != nil {
+		return r.tok, r.err
+	}
+	if r.s.Scan() {
+		r.tok, r.peeked = r.s.Token(), true
+		return r.tok, nil
+	}
+	r.err = r.s.Err()
+	if r.err == nil {
+		r.err = io.EOF
+	}
+	return r.tok, r.err
+}
+
+// Token returns the next token. It returns io.EOF after the EOF token.
+func (r *NodeReader) Token() (Token, error) {
+	tok, err := r.Peek()
+	r.peeked = false
+	return tok, err
+}
+
+// More reports whether there is another node in the current list.
+func (r *NodeReader) More() bool {
+	tok, err := r.Peek()
+	if err != nil {
+		return false
+	}
+	return tok.Type != Unindent && tok.Type != EOF
+}
+
+// ReadNode reads the next node in the current list together with its
+// annotations and descendants.
+func (r *NodeReader) ReadNode() (*Node, error) {
+	var a []string
+	var aLines []int
+	for {
+		tok, err := r.Token()
+		if err != nil {
+			return nil, err
+		}
+		switch tok.Type {
+		case Annotation:
+			a, aLines = append(a, tok.Content), append(aLines, tok.Line)
+			continue
+		case LineValue, Reference:
+			node := &Node{
+				Value:           tok.Content,
+				IsReference:     tok.Type == Reference,
+				Annotations:     a,
+				Line:            tok.Line,
+				AnnotationLines: aLines,
+			}
+			return node, r.readChildren(node)
+		case Indent:
+			if len(a) > 0 {
+				return nil, &SyntaxError{aLines[0], errAnnotationWithoutNode}
+			}
+			return nil, &SyntaxError{tok.Line, errWrongIndent}
+		}
+		if len(a) > 0 {
+			return nil, &SyntaxError{aLines[0], errAnnotationWithoutNode}
+		}
+		return nil, &SyntaxError{tok.Line, errUnexpectedToken}
+	}
+}
+
+func (r *NodeReader) readChildren(node *Node) error {
+	tok, err := r.Peek()
+	if err != nil || tok.Type != Indent {
+		return nil
+	}
+	r.Token()
+	for r.More() {
+		child, err := r.ReadNode()
+		if err != nil {
+			return err
+		}
+		node.List = append(node.List, *child)
+	}
+	if tok, err := r.Token(); err != nil {
+		return err
+	} else if tok.Type != Unindent {
+		return errUnexpectedToken
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+package core
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestNodeReader(t *testing.T) {
+	for i, testcase := range typeTestCases {
+		r := NewNodeReader(strings.NewReader(strings.Trim(testcase.s, "\n")))
+		list := List{}
+		for r.More() {
+			node, err := r.ReadNode()
+			if err != nil {
+				t.Fatalf("testcase %d: %v", i, err)
+			}
+			list = append(list, *node)
+		}
+		if tok, err := r.Token(); err != nil || tok.Type != EOF {
+			t.Fatalf("testcase %d: expect EOF but got %v, %v", i, tok, err)
+		}
+		if !reflect.DeepEqual(list, testcase.v) {
+			t.Fatalf("testcase %d: expect \n%#v\nbut got \n%#v", i, testcase.v, list)
+		}
+	}
+}
+
+func TestNodeReaderToken(t *testing.T) {
+	r := NewNodeReader(strings.NewReader("records:\n\t1\n\t#x\n\t2\n\t\t3\nend"))
+	if tok, err := r.Token(); err != nil || tok.Content != "records:" {
+		t.Fatalf("unexpected %v, %v", tok, err)
+	}
+	if tok, err := r.Token(); err != nil || tok.Type != Indent {
+		t.Fatalf("unexpected %v, %v", tok, err)
+	}
+	var values []string
+	for r.More() {
+		node, err := r.ReadNode()
+		if err != nil {
+			t.Fatal(err)
+		}
+		values = append(values, List{*node}.String())
+	}
+	if expected := []string{"1", "#x\n2\n\t3"}; !reflect.DeepEqual(values, expected) {
+		t.Fatalf("expect %q but got %q", expected, values)
+	}
+	if tok, err := r.Token(); err != nil || tok.Type != Unindent {
+		t.Fatalf("unexpected %v, %v", tok, err)
+	}
+	if node, err := r.ReadNode(); err != nil || node.Value != "end" {
+		t.Fatalf("unexpected %v, %v", node, err)
+	}
+}
+
+func TestNodeReaderError(t *testing.T) {
+	for i, testcase := range []string{
+		"\ta",
+		"\x00",
+		"a\n    #a\nb",
+		"a\n#b\n    b",
+		"#a",
+	} {
+		r := NewNodeReader(strings.NewReader(testcase))
+		var err error
+		for r.More() && err == nil {
+			_, err = r.ReadNode()
+		}
+		if err == nil {
+			_, err = r.Token()
+		}
+		if err == nil {
+			t.Fatalf("testcase %d: expect error but got nil", i)
+		}
+	}
+}
+
+func TestNodeReaderErrorLine(t *testing.T) {
+	r := NewNodeReader(strings.NewReader("a\n# b\n# c"))
+	if _, err := r.ReadNode(); err != nil {
+		t.Fatal(err)
+	}
+	_, err := r.ReadNode()
+	if expected := "line 2: syntax error, annotation without a node"; err == nil || err.Error() != expected {
+		t.Fatalf("expect error %q but got %v", expected, err)
+	}
+}
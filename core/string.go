@@ -0,0 +1,186 @@
+package core
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// StringError is an error of an interpreted string at a byte offset.
+type StringError struct {
+	Offset int
+	Msg    string
+}
+
+func (e *StringError) Error() string {
+	return fmt.Sprintf("invalid string at offset %d: %s", e.Offset, e.Msg)
+}
+
+const hexDigits = "0123456789abcdef"
+
+// Quote returns s as an interpreted string. A character that is not
+// char_visible or a space is escaped, and so is a byte of invalid UTF-8.
+func Quote(s string) string {
+	var b strings.Builder
+	b.Grow(len(s) + 2)
+	b.WriteByte('"')
+	for i := 0; i < len(s); {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		switch {
+		case r == utf8.RuneError && size == 1:
+			b.WriteString(`\x`)
+			b.WriteByte(hexDigits[s[i]>>4])
+			b.WriteByte(hexDigits[s[i]&0xf])
+		case r == utf8.RuneError:
+			b.WriteString(`\uFFFD`)
+		case r == '"' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteByte(byte(r))
+		case r < ' ':
+			if c := escapeChar(byte(r)); c != 0 {
+				b.WriteByte('\\')
+				b.WriteByte(c)
+			} else {
+				b.WriteString(`\x`)
+				b.WriteByte(hexDigits[r>>4])
+				b.WriteByte(hexDigits[r&0xf])
+			}
+		default:
+			b.WriteString(s[i : i+size])
+		}
+		i += size
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+// Unquote returns the string represented by the interpreted string s. The
+// offset of a StringError is that of the first invalid byte in s.
+func Unquote(s string) (string, error) {
+	if len(s) < 2 || s[0] != '"' || s[len(s)-1] != '"' {
+		return "", &StringError{0, "expect a double quoted string"}
+	}
+	var b strings.Builder
+	b.Grow(len(s) - 2)
+	for i := 1; i < len(s)-1; {
+		c := s[i]
+		switch {
+		case c == '"':
+			return "", &StringError{i, "unescaped double quote"}
+		case c == '\\':
+			n, err := unescape(&b, s[i:len(s)-1])
+			if err != nil {
+				err.Offset += i
+				return "", err
+			}
+			i += n
+		case c < ' ' && c != '\t':
+			return "", &StringError{i, fmt.Sprintf("invalid character %q", c)}
+		default:
+			// a literal U+FFFD is valid, unlike a byte decoded as one
+			r, size := utf8.DecodeRuneInString(s[i:])
+			if r == utf8.RuneError && size == 1 {
+				return "", &StringError{i, "invalid UTF-8"}
+			}
+			b.WriteString(s[i : i+size])
+			i += size
+		}
+	}
+	return b.String(), nil
+}
+
+// unescape writes the character of the escape sequence at the start of s and
+// returns the length of the sequence.
+func unescape(b *strings.Builder, s string) (int, *StringError) {
+	if len(s) < 2 {
+		return 0, &StringError{0, "incomplete escape sequence"}
+	}
+	if c := unescapeChar(s[1]); c != 0 {
+		b.WriteByte(c)
+		return 2, nil
+	}
+	var n int
+	switch s[1] {
+	case 'x':
+		n = 2
+	case 'u':
+		n = 4
+	case 'U':
+		n = 8
+	default:
+		return 0, &StringError{1, fmt.Sprintf("unknown escape sequence \\%c", s[1])}
+	}
+	if len(s) < 2+n {
+		return 0, &StringError{0, "incomplete escape sequence"}
+	}
+	var v rune
+	for i := 2; i < 2+n; i++ {
+		d := unhex(s[i])
+		if d < 0 {
+			return 0, &StringError{i, fmt.Sprintf("invalid hexadecimal digit %q", s[i])}
+		}
+		v = v<<4 | d
+	}
+	if n == 2 {
+		b.WriteByte(byte(v))
+	} else if !utf8.ValidRune(v) {
+		return 0, &StringError{0, fmt.Sprintf("invalid code point %s", s[:2+n])}
+	} else {
+		b.WriteRune(v)
+	}
+	return 2 + n, nil
+}
+
+func escapeChar(c byte) byte {
+	switch c {
+	case '\a':
+		return 'a'
+	case '\b':
+		return 'b'
+	case '\t':
+		return 't'
+	case '\n':
+		return 'n'
+	case '\v':
+		return 'v'
+	case '\f':
+		return 'f'
+	case '\r':
+		return 'r'
+	}
+	return 0
+}
+
+func unescapeChar(c byte) byte {
+	switch c {
+	case 'a':
+		return '\a'
+	case 'b':
+		return '\b'
+	case 't':
+		return '\t'
+	case 'n':
+		return '\n'
+	case 'v':
+		return '\v'
+	case 'f':
+		return '\f'
+	case 'r':
+		return '\r'
+	case '\\', '"':
+		return c
+	}
+	return 0
+}
+
+func unhex(c byte) rune {
+	switch {
+	case '0' <= c && c <= '9':
+		return rune(c - '0')
+	case 'a' <= c && c <= 'f':
+		return rune(c - 'a' + 10)
+	case 'A' <= c && c <= 'F':
+		return rune(c - 'A' + 10)
+	}
+	return -1
+}
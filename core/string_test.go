@@ -0,0 +1,70 @@
+package core
+
+import (
+	"testing"
+)
+
+func TestQuote(t *testing.T) {
+	for i, testcase := range []struct {
+		s, quoted string
+	}{
+		{"", `""`},
+		{"a b", `"a b"`},
+		{"\"\\", `"\"\\"`},
+		{"\a\b\t\n\v\f\r", `"\a\b\t\n\v\f\r"`},
+		{"\x00\x1b\x7f", `"\x00\x1b` + "\x7f" + `"`},
+		{"日本 ", "\"日本 \""},
+		{"\xff\xfe", `"\xff\xfe"`},
+		{"\uFFFD", `"\uFFFD"`},
+	} {
+		if quoted := Quote(testcase.s); quoted != testcase.quoted {
+			t.Fatalf("testcase %d: expect %s but got %s", i, testcase.quoted, quoted)
+		}
+		if s, err := Unquote(testcase.quoted); err != nil || s != testcase.s {
+			t.Fatalf("testcase %d: expect %q but got %q, %v", i, testcase.s, s, err)
+		}
+	}
+}
+
+func TestUnquote(t *testing.T) {
+	for i, testcase := range []struct {
+		quoted, s string
+	}{
+		{`"\x41é\U0001F600"`, "Aé\U0001F600"},
+		{`"\xAB"`, "\xab"},
+		{"\"a\tb\"", "a\tb"},
+		{"\"a\uFFFDb\"", "a\uFFFDb"},
+		{`"\uFFFD"`, "\uFFFD"},
+	} {
+		if s, err := Unquote(testcase.quoted); err != nil || s != testcase.s {
+			t.Fatalf("testcase %d: expect %q but got %q, %v", i, testcase.s, s, err)
+		}
+	}
+}
+
+func TestUnquoteError(t *testing.T) {
+	for i, testcase := range []struct {
+		quoted string
+		err    string
+	}{
+		{"", "invalid string at offset 0: expect a double quoted string"},
+		{"`a`", "invalid string at offset 0: expect a double quoted string"},
+		{`"a"b"`, "invalid string at offset 2: unescaped double quote"},
+		{`"ab\"`, "invalid string at offset 3: incomplete escape sequence"},
+		{`"ab\`, "invalid string at offset 0: expect a double quoted string"},
+		{`"a\"`, "invalid string at offset 2: incomplete escape sequence"},
+		{`"a\q"`, `invalid string at offset 3: unknown escape sequence \q`},
+		{`"\101"`, `invalid string at offset 2: unknown escape sequence \1`},
+		{`"a\x4"`, "invalid string at offset 2: incomplete escape sequence"},
+		{`"\u00g0"`, `invalid string at offset 5: invalid hexadecimal digit 'g'`},
+		{`"\uD800"`, `invalid string at offset 1: invalid code point \uD800`},
+		{`"\U00110000"`, `invalid string at offset 1: invalid code point \U00110000`},
+		{"\"a\nb\"", `invalid string at offset 2: invalid character '\n'`},
+		{"\"a\xffb\"", "invalid string at offset 2: invalid UTF-8"},
+	} {
+		_, err := Unquote(testcase.quoted)
+		if err == nil || err.Error() != testcase.err {
+			t.Fatalf("testcase %d: expect error %q but got %v", i, testcase.err, err)
+		}
+	}
+}
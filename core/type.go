@@ -6,6 +6,9 @@ type (
 		IsReference bool
 		List        List
 		Annotations []string
+		Line        int
+		// AnnotationLines are the lines of Annotations if they are parsed.
+		AnnotationLines []int
 	}
 	List []Node
 )
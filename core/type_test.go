@@ -7,20 +7,20 @@ var typeTestCases = []struct {
 	{List{}, ""},
 
 	{List{
-		{"a", false, nil, nil},
+		{"a", false, nil, nil, 1, nil},
 	}, `
 a
 `},
 
 	{List{
-		{"a", true, nil, nil},
+		{"a", true, nil, nil, 1, nil},
 	}, `
 ^a
 `},
 
 	{List{
-		{"a", false, nil, nil},
-		{"b", false, nil, nil},
+		{"a", false, nil, nil, 1, nil},
+		{"b", false, nil, nil, 2, nil},
 	}, `
 a
 b
@@ -29,11 +29,11 @@ b
 	{List{
 		{"a", false, List{
 			{"b", false, List{
-				{"c", false, nil, nil},
-			}, nil},
-			{"d", false, nil, nil},
-		}, nil},
-		{"e", false, nil, nil},
+				{"c", false, nil, nil, 3, nil},
+			}, nil, 2, nil},
+			{"d", false, nil, nil, 4, nil},
+		}, nil, 1, nil},
+		{"e", false, nil, nil, 5, nil},
 	}, `
 a
 	b
@@ -43,14 +43,14 @@ e
 `},
 
 	{List{
-		{"a", false, nil, []string{"a1"}},
+		{"a", false, nil, []string{"a1"}, 2, []int{1}},
 	}, `
 #a1
 a
 `},
 
 	{List{
-		{"a", false, nil, []string{"a1", "a2"}},
+		{"a", false, nil, []string{"a1", "a2"}, 3, []int{1, 2}},
 	}, `
 #a1
 #a2
@@ -58,8 +58,8 @@ a
 `},
 
 	{List{
-		{"a", false, nil, []string{"a1", "a2"}},
-		{"b", false, nil, []string{"b1", "b2"}},
+		{"a", false, nil, []string{"a1", "a2"}, 3, []int{1, 2}},
+		{"b", false, nil, []string{"b1", "b2"}, 6, []int{4, 5}},
 	}, `
 #a1
 #a2
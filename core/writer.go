@@ -0,0 +1,151 @@
+package core
+
+import (
+	"errors"
+	"io"
+	"unicode"
+)
+
+var (
+	errInvalidLine     = errors.New("invalid line content")
+	errBeginWithout    = errors.New("begin without a value node")
+	errEndWithout      = errors.New("end without a matching begin")
+	errUnclosedBegin   = errors.New("begin without a matching end")
+	errWriteAfterClose = errors.New("write after close")
+)
+
+// Writer writes a TEFF stream token by token without building a List.
+type Writer struct {
+	ew       errWriter
+	prefix   string
+	indent   string
+	depth    int
+	started  bool
+	canBegin bool
+	pending  bool
+	closed   bool
+}
+
+func NewWriter(w io.Writer, prefix, indent string) *Writer {
+	return &Writer{ew: newErrWriter(w), prefix: prefix, indent: indent}
+}
+
+// Annotation writes an annotation line of the next node.
+func (w *Writer) Annotation(s string) error {
+	if !isInline(s) {
+		return w.fail(errInvalidLine)
+	}
+	w.writeLine('#', s)
+	w.pending, w.canBegin = true, false
+	return w.ew.err
+}
+
+// Value writes a value node.
+func (w *Writer) Value(s string) error {
+	if !IsValue(s) {
+		return w.fail(errInvalidLine)
+	}
+	w.writeLine(0, s)
+	w.pending, w.canBegin = false, true
+	return w.ew.err
+}
+
+// Reference writes a reference node.
+func (w *Writer) Reference(s string) error {
+	if !isInline(s) {
+		return w.fail(errInvalidLine)
+	}
+	w.writeLine('^', s)
+	w.pending, w.canBegin = false, false
+	return w.ew.err
+}
+
+// Begin starts the child list of the last value node.
+func (w *Writer) Begin() error {
+	if w.pending {
+		return w.fail(errAnnotationWithoutNode)
+	}
+	if !w.canBegin {
+		return w.fail(errBeginWithout)
+	}
+	w.depth++
+	w.canBegin = false
+	return w.ew.err
+}
+
+// End ends the current child list.
+func (w *Writer) End() error {
+	if w.pending {
+		return w.fail(errAnnotationWithoutNode)
+	}
+	if w.depth == 0 {
+		return w.fail(errEndWithout)
+	}
+	w.depth--
+	w.canBegin = false
+	return w.ew.err
+}
+
+// Flush writes any buffered data to the underlying io.Writer.
+func (w *Writer) Flush() error {
+	w.ew.flush()
+	return w.ew.err
+}
+
+// Close checks that the stream is complete and flushes it.
+func (w *Writer) Close() error {
+	if w.pending {
+		w.fail(errAnnotationWithoutNode)
+	} else if w.depth > 0 {
+		w.fail(errUnclosedBegin)
+	}
+	w.closed = true
+	return w.Flush()
+}
+
+func (w *Writer) writeLine(mark byte, s string) {
+	if w.closed {
+		w.fail(errWriteAfterClose)
+		return
+	}
+	if w.started {
+		w.ew.writeByte('\n')
+	}
+	w.started = true
+	w.ew.writeString(w.prefix)
+	for i := 0; i < w.depth; i++ {
+		w.ew.writeString(w.indent)
+	}
+	if mark != 0 {
+		w.ew.writeByte(mark)
+	}
+	w.ew.writeString(s)
+}
+
+func (w *Writer) fail(err error) error {
+	if w.ew.err == nil {
+		w.ew.err = err
+	}
+	return w.ew.err
+}
+
+// IsValue reports whether s can be written as a value line.
+func IsValue(s string) bool {
+	if s == "" {
+		return false
+	}
+	switch s[0] {
+	case ' ', '\t', '#', '^':
+		return false
+	}
+	return isInline(s)
+}
+
+func isInline(s string) bool {
+	for _, r := range s {
+		if r < ' ' && r != '\t' || r == unicode.ReplacementChar {
+			return false
+		}
+	}
+	return true
+}
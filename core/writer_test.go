@@ -0,0 +1,98 @@
+package core
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf, "", "\t")
+	w.Value("a")
+	w.Begin()
+	w.Value("b")
+	w.Begin()
+	w.Value("c")
+	w.End()
+	w.Value("d")
+	w.End()
+	w.Annotation("a1")
+	w.Annotation(" ^1")
+	w.Value("e")
+	w.Reference("1")
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	expected := "a\n\tb\n\t\tc\n\td\n#a1\n# ^1\ne\n^1"
+	if buf.String() != expected {
+		t.Fatalf("expect \n%s\nbut got \n%s", expected, buf.String())
+	}
+	list, err := Parse(strings.NewReader(expected))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if list.String() != expected {
+		t.Fatalf("expect \n%s\nbut got \n%s", expected, list.String())
+	}
+}
+
+func TestWriterList(t *testing.T) {
+	for i, testcase := range typeTestCases {
+		var buf bytes.Buffer
+		w := NewWriter(&buf, "", "\t")
+		writeList(w, testcase.v)
+		if err := w.Close(); err != nil {
+			t.Fatalf("testcase %d: %v", i, err)
+		}
+		if expected := strings.Trim(testcase.s, "\n"); buf.String() != expected {
+			t.Fatalf("testcase %d: expect \n%s\nbut got \n%s", i, expected, buf.String())
+		}
+	}
+}
+
+func writeList(w *Writer, list List) {
+	for _, node := range list {
+		for _, a := range node.Annotations {
+			w.Annotation(a)
+		}
+		if node.IsReference {
+			w.Reference(node.Value)
+			continue
+		}
+		w.Value(node.Value)
+		if len(node.List) > 0 {
+			w.Begin()
+			writeList(w, node.List)
+			w.End()
+		}
+	}
+}
+
+func TestWriterError(t *testing.T) {
+	for i, write := range []func(w *Writer) error{
+		func(w *Writer) error { return w.Value("") },
+		func(w *Writer) error { return w.Value(" a") },
+		func(w *Writer) error { return w.Value("#a") },
+		func(w *Writer) error { return w.Value("^a") },
+		func(w *Writer) error { return w.Value("a\nb") },
+		func(w *Writer) error { return w.Annotation("a\x00") },
+		func(w *Writer) error { return w.Begin() },
+		func(w *Writer) error { return w.End() },
+		func(w *Writer) error { w.Reference("a"); return w.Begin() },
+		func(w *Writer) error { w.Value("a"); w.Annotation("x"); return w.Begin() },
+		func(w *Writer) error { w.Value("a"); w.Begin(); w.Value("b"); w.Annotation("x"); return w.End() },
+		func(w *Writer) error { w.Annotation("x"); return w.Close() },
+		func(w *Writer) error { w.Value("a"); w.Begin(); return w.Close() },
+		func(w *Writer) error { w.Close(); return w.Value("a") },
+	} {
+		var buf bytes.Buffer
+		w := NewWriter(&buf, "", "\t")
+		if err := write(w); err == nil {
+			t.Fatalf("testcase %d: expect error but got nil", i)
+		}
+		if err := w.Flush(); err == nil {
+			t.Fatalf("testcase %d: expect sticky error but got nil", i)
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package teff
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// UnmarshalError is an error decoding the node at a line and a path into a
+// value of a Go type. Value is the text of the node, or empty if the error is
+// about the list of a key or an element as a whole.
+type UnmarshalError struct {
+	Line  int
+	Path  string
+	Type  reflect.Type
+	Value string
+	Err   error
+}
+
+func (e *UnmarshalError) Error() string {
+	if e.Value == "" {
+		return fmt.Sprintf("unmarshal: line %d: %s: cannot decode into %s: %v", e.Line, e.Path, e.Type, e.Err)
+	}
+	return fmt.Sprintf("unmarshal: line %d: %s: cannot decode %q into %s: %v", e.Line, e.Path, e.Value, e.Type, e.Err)
+}
+
+func (e *UnmarshalError) Unwrap() error {
+	return e.Err
+}
+
+// UnmarshalErrors is the list of all the errors found when decoding a value.
+type UnmarshalErrors []*UnmarshalError
+
+func (es UnmarshalErrors) Error() string {
+	ss := make([]string, len(es))
+	for i, e := range es {
+		ss[i] = e.Error()
+	}
+	return strings.Join(ss, "\n")
+}
+
+func (es UnmarshalErrors) Unwrap() []error {
+	errs := make([]error, len(es))
+	for i, e := range es {
+		errs[i] = e
+	}
+	return errs
+}
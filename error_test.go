@@ -0,0 +1,58 @@
+package teff
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestUnmarshalError(t *testing.T) {
+	var v testStruct
+	err := Unmarshal([]byte("name:\n\ta\nPort:\n\tx\nOn:\n\tyes\ntags:\n\tt\n\t\"\nInner:\n\tIP:\n\t\t1.2\n\tHome:\n\t\t:"), &v)
+	expected := []string{
+		`unmarshal: line 4: ^Port: cannot decode "x" into uint16: strconv.ParseUint: parsing "x": invalid syntax`,
+		`unmarshal: line 6: ^On: cannot decode "yes" into bool: strconv.ParseBool: parsing "yes": invalid syntax`,
+		`unmarshal: line 9: ^tags[1]: cannot decode "\"" into string: invalid string at offset 0: expect a double quoted string`,
+		`unmarshal: line 12: ^Inner:IP: cannot decode "1.2" into net.IP: invalid IP address: 1.2`,
+		`unmarshal: line 14: ^Inner:Home: cannot decode ":" into url.URL: parse ":": missing protocol scheme`,
+	}
+	if err == nil || err.Error() != strings.Join(expected, "\n") {
+		t.Fatalf("expect error\n%s\nbut got\n%v", strings.Join(expected, "\n"), err)
+	}
+	var es UnmarshalErrors
+	if !errors.As(err, &es) || len(es) != len(expected) {
+		t.Fatalf("expect %d UnmarshalErrors but got %#v", len(expected), err)
+	}
+	if e := es[0]; e.Line != 4 || e.Path != "^Port" || e.Type.String() != "uint16" || e.Value != "x" {
+		t.Fatalf("unexpected %#v", e)
+	}
+	if !errors.Is(err, strconv.ErrSyntax) {
+		t.Fatalf("expect strconv.ErrSyntax in %v", err)
+	}
+	if v.Name != "a" || len(v.Tags) != 2 {
+		t.Fatalf("expect the valid fields decoded but got %#v", v)
+	}
+}
+
+func TestUnmarshalErrorPath(t *testing.T) {
+	for i, testcase := range []struct {
+		text string
+		v    interface{}
+		path string
+		line int
+	}{
+		{"", new(int), "^", 1},
+		{"X:\n\t1\n\tz", &struct{ X []*int }{}, "^X[1]", 3},
+		{"_\n\tA:\n\t\t1\n_\n\tA:", &[]struct{ A int }{}, "^[1]A", 5},
+		{"a:b:\n\tx", &struct {
+			A int `teff:"a:b"`
+		}{}, `^"a:b"`, 2},
+	} {
+		err := Unmarshal([]byte(testcase.text), testcase.v)
+		es, ok := err.(UnmarshalErrors)
+		if !ok || len(es) != 1 || es[0].Path != testcase.path || es[0].Line != testcase.line {
+			t.Fatalf("testcase %d: expect an error at %s line %d but got %v", i, testcase.path, testcase.line, err)
+		}
+	}
+}
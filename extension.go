@@ -0,0 +1,68 @@
+package teff
+
+import (
+	"net"
+	"net/url"
+	"reflect"
+	"time"
+)
+
+// extension is the encoding of a type defined by a TEFF extension.
+type extension struct {
+	marshal   func(v reflect.Value) string
+	unmarshal func(s string, v reflect.Value) error
+}
+
+var extensions = map[reflect.Type]extension{
+	reflect.TypeOf(time.Time{}): {
+		marshal: func(v reflect.Value) string {
+			return v.Interface().(time.Time).Format(time.RFC3339Nano)
+		},
+		unmarshal: func(s string, v reflect.Value) error {
+			t, err := time.Parse(time.RFC3339Nano, s)
+			if err != nil {
+				return err
+			}
+			v.Set(reflect.ValueOf(t))
+			return nil
+		},
+	},
+	reflect.TypeOf(net.IP{}): {
+		marshal: func(v reflect.Value) string {
+			if v.IsNil() {
+				return "nil"
+			}
+			return v.Interface().(net.IP).String()
+		},
+		unmarshal: func(s string, v reflect.Value) error {
+			if s == "nil" {
+				v.Set(reflect.Zero(v.Type()))
+				return nil
+			}
+			ip := net.ParseIP(s)
+			if ip == nil {
+				return &net.ParseError{Type: "IP address", Text: s}
+			}
+			v.Set(reflect.ValueOf(ip))
+			return nil
+		},
+	},
+	reflect.TypeOf(url.URL{}): {
+		marshal: func(v reflect.Value) string {
+			u := v.Interface().(url.URL)
+			return FormatString(u.String())
+		},
+		unmarshal: func(s string, v reflect.Value) error {
+			s, err := ParseString(s)
+			if err != nil {
+				return err
+			}
+			u, err := url.Parse(s)
+			if err != nil {
+				return err
+			}
+			v.Set(reflect.ValueOf(*u))
+			return nil
+		},
+	},
+}
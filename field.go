@@ -0,0 +1,70 @@
+package teff
+
+import (
+	"reflect"
+	"strings"
+)
+
+// StructField is a struct field that is encoded as a key-value pair.
+type StructField struct {
+	Name      string
+	Index     []int
+	Type      reflect.Type
+	OmitEmpty bool
+	Redact    bool
+}
+
+// StructFields returns the encoded fields of struct type t. A field is
+// encoded with its Go name unless renamed by a tag like `teff:"name"`, and
+// is skipped if it is unexported or tagged with `teff:"-"`. The option
+// "omitempty", e.g. `teff:",omitempty"`, omits the field when it is empty,
+// and the option "redact" encodes the field as RedactedValue.
+func StructFields(t reflect.Type) []StructField {
+	var fields []StructField
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+		tag := f.Tag.Get("teff")
+		if tag == "-" {
+			continue
+		}
+		name, opts := tag, ""
+		if i := strings.IndexByte(tag, ','); i >= 0 {
+			name, opts = tag[:i], tag[i+1:]
+		}
+		if name == "" {
+			name = f.Name
+		}
+		field := StructField{Name: name, Index: f.Index, Type: f.Type}
+		for _, opt := range strings.Split(opts, ",") {
+			switch opt {
+			case "omitempty":
+				field.OmitEmpty = true
+			case "redact":
+				field.Redact = true
+			}
+		}
+		fields = append(fields, field)
+	}
+	return fields
+}
+
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+	case reflect.Interface, reflect.Ptr:
+		return v.IsNil()
+	}
+	return false
+}
@@ -0,0 +1,295 @@
+// Package jsonteff converts between JSON documents and TEFF lists.
+//
+// An object is encoded as a list of key nodes "key:" with the value as the
+// child list, an array as a list of its elements, and an element that is
+// itself an object or array as an anonymous parent "_". A container whose
+// type cannot be told from its children, i.e. an empty one or an array of a
+// single element, is marked with a type label "<object>" or "<array>" on its
+// parent node, and a top level one is wrapped in a labeled "_" node.
+package jsonteff
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"h12.io/teff"
+	"h12.io/teff/core"
+	"io"
+	"strconv"
+	"strings"
+)
+
+const (
+	anonymous   = "_"
+	objectLabel = "object"
+	arrayLabel  = "array"
+)
+
+// FromJSON reads a JSON document and converts it to a list, preserving the
+// order of object keys and the text of numbers.
+func FromJSON(r io.Reader) (core.List, error) {
+	dec := json.NewDecoder(r)
+	dec.UseNumber()
+	v, err := readValue(dec)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, errors.New("jsonteff: trailing data after JSON value")
+	}
+	list, label := v.list()
+	if label != "" {
+		node := core.Node{Value: anonymous, List: list}
+		node.SetTypeLabel(label)
+		return core.List{node}, nil
+	}
+	return list, nil
+}
+
+// ToJSON converts list to JSON and writes it to w.
+func ToJSON(w io.Writer, list core.List) error {
+	v, err := fromList(list, "")
+	if len(list) == 1 && list[0].Value == anonymous && list[0].TypeLabel() != "" {
+		v, err = fromList(list[0].List, list[0].TypeLabel())
+	}
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	v.writeJSON(&buf)
+	_, err = w.Write(buf.Bytes())
+	return err
+}
+
+// value is an ordered JSON value.
+type value struct {
+	scalar interface{} // nil, bool, json.Number or string
+	object []member
+	array  []*value
+	kind   byte // 0 for scalar, '{' for object, '[' for array
+}
+
+type member struct {
+	key   string
+	value *value
+}
+
+func readValue(dec *json.Decoder) (*value, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch t := tok.(type) {
+	case json.Delim:
+		switch t {
+		case '{':
+			v := &value{kind: '{', object: []member{}}
+			for dec.More() {
+				tok, err := dec.Token()
+				if err != nil {
+					return nil, err
+				}
+				elem, err := readValue(dec)
+				if err != nil {
+					return nil, err
+				}
+				v.object = append(v.object, member{tok.(string), elem})
+			}
+			_, err := dec.Token()
+			return v, err
+		case '[':
+			v := &value{kind: '[', array: []*value{}}
+			for dec.More() {
+				elem, err := readValue(dec)
+				if err != nil {
+					return nil, err
+				}
+				v.array = append(v.array, elem)
+			}
+			_, err := dec.Token()
+			return v, err
+		}
+		return nil, fmt.Errorf("jsonteff: unexpected delimiter %v", t)
+	}
+	return &value{scalar: tok}, nil
+}
+
+// list returns the child list encoding v, and the type label its parent
+// needs when the type of v cannot be told from the list.
+func (v *value) list() (core.List, string) {
+	switch v.kind {
+	case '{':
+		list := make(core.List, len(v.object))
+		for i, m := range v.object {
+			list[i] = core.Node{Value: teff.FormatString(m.key) + ":"}
+			var label string
+			list[i].List, label = m.value.list()
+			list[i].SetTypeLabel(label)
+		}
+		if len(list) == 0 {
+			return list, objectLabel
+		}
+		return list, ""
+	case '[':
+		list := make(core.List, len(v.array))
+		for i, elem := range v.array {
+			if elem.kind == 0 {
+				list[i] = core.Node{Value: encodeScalar(elem.scalar)}
+				continue
+			}
+			list[i] = core.Node{Value: anonymous}
+			var label string
+			list[i].List, label = elem.list()
+			list[i].SetTypeLabel(label)
+		}
+		if len(list) <= 1 {
+			return list, arrayLabel
+		}
+		return list, ""
+	}
+	return core.List{{Value: encodeScalar(v.scalar)}}, ""
+}
+
+func encodeScalar(s interface{}) string {
+	switch s := s.(type) {
+	case nil:
+		return "nil"
+	case bool:
+		return strconv.FormatBool(s)
+	case json.Number:
+		return s.String()
+	case string:
+		if isNumber(s) {
+			// a JSON number may not be a TEFF one, e.g. 1e05
+			return core.Quote(s)
+		}
+		return teff.FormatUntyped(s)
+	}
+	panic("unreachable")
+}
+
+func isNumber(s string) bool {
+	if s == "" || (s[0] != '-' && (s[0] < '0' || s[0] > '9')) {
+		return false
+	}
+	var n json.Number
+	return json.Unmarshal([]byte(s), &n) == nil
+}
+
+func fromList(list core.List, label string) (*value, error) {
+	isObject := len(list) > 0 && isKey(&list[0])
+	switch {
+	case label == objectLabel || (label == "" && isObject):
+		v := &value{kind: '{', object: []member{}}
+		for i := range list {
+			node := &list[i]
+			if !isKey(node) {
+				return nil, fmt.Errorf("jsonteff: line %d: expect a key but got %s", node.Line, node.Value)
+			}
+			key, err := teff.ParseString(strings.TrimSuffix(node.Value, ":"))
+			if err != nil {
+				return nil, fmt.Errorf("jsonteff: line %d: %v", node.Line, err)
+			}
+			elem, err := fromList(node.List, node.TypeLabel())
+			if err != nil {
+				return nil, err
+			}
+			v.object = append(v.object, member{key, elem})
+		}
+		return v, nil
+	case label == "" && len(list) == 0:
+		return &value{}, nil
+	case label == arrayLabel || (label == "" && len(list) > 1):
+		v := &value{kind: '[', array: []*value{}}
+		for i := range list {
+			elem, err := fromNode(&list[i])
+			if err != nil {
+				return nil, err
+			}
+			v.array = append(v.array, elem)
+		}
+		return v, nil
+	case label == "":
+		return fromNode(&list[0])
+	}
+	return nil, fmt.Errorf("jsonteff: unknown type label <%s>", label)
+}
+
+func fromNode(node *core.Node) (*value, error) {
+	if node.IsReference {
+		return nil, fmt.Errorf("jsonteff: line %d: reference is not supported", node.Line)
+	}
+	if isKey(node) {
+		return nil, fmt.Errorf("jsonteff: line %d: unexpected key %s", node.Line, node.Value)
+	}
+	if node.Value == anonymous {
+		label := node.TypeLabel()
+		if label == "" {
+			label = arrayLabel
+			if len(node.List) > 0 && isKey(&node.List[0]) {
+				label = objectLabel
+			}
+		}
+		return fromList(node.List, label)
+	}
+	if len(node.List) > 0 {
+		return nil, fmt.Errorf("jsonteff: line %d: unexpected child list of %s", node.Line, node.Value)
+	}
+	switch node.Value {
+	case "nil":
+		return &value{}, nil
+	case "true", "false":
+		return &value{scalar: node.Value == "true"}, nil
+	}
+	if isNumber(node.Value) {
+		return &value{scalar: json.Number(node.Value)}, nil
+	}
+	s, err := teff.ParseString(node.Value)
+	if err != nil {
+		return nil, fmt.Errorf("jsonteff: line %d: %v", node.Line, err)
+	}
+	return &value{scalar: s}, nil
+}
+
+func isKey(node *core.Node) bool {
+	return !node.IsReference && len(node.Value) > 1 && strings.HasSuffix(node.Value, ":")
+}
+
+func (v *value) writeJSON(w *bytes.Buffer) {
+	switch v.kind {
+	case '{':
+		w.WriteByte('{')
+		for i, m := range v.object {
+			if i > 0 {
+				w.WriteByte(',')
+			}
+			writeScalar(w, m.key)
+			w.WriteByte(':')
+			m.value.writeJSON(w)
+		}
+		w.WriteByte('}')
+	case '[':
+		w.WriteByte('[')
+		for i, elem := range v.array {
+			if i > 0 {
+				w.WriteByte(',')
+			}
+			elem.writeJSON(w)
+		}
+		w.WriteByte(']')
+	default:
+		writeScalar(w, v.scalar)
+	}
+}
+
+func writeScalar(w *bytes.Buffer, s interface{}) {
+	if n, ok := s.(json.Number); ok {
+		w.WriteString(n.String())
+		return
+	}
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	enc.Encode(s)
+	w.Truncate(w.Len() - 1) // remove the newline appended by Encode
+}
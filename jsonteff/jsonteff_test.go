@@ -0,0 +1,70 @@
+package jsonteff
+
+import (
+	"bytes"
+	"h12.io/teff/core"
+	"strings"
+	"testing"
+)
+
+func TestConvert(t *testing.T) {
+	for i, testcase := range []struct {
+		json string
+		teff string
+	}{
+		{`1`, `1`},
+		{`null`, `nil`},
+		{`"a"`, `a`},
+		{`"1"`, `"1"`},
+		{`1.50e+10`, `1.50e+10`},
+		{`12345678901234567890123`, `12345678901234567890123`},
+		{`[1,"true",null]`, "1\n\"true\"\nnil"},
+		{`[]`, "#<array>\n_"},
+		{`{}`, "#<object>\n_"},
+		{`[5]`, "#<array>\n_\n\t5"},
+		{`{"b":1,"a":[true,false],"":"x"}`, "b:\n\t1\na:\n\ttrue\n\tfalse\n\"\":\n\tx"},
+		{`{"a":[],"b":{},"c":[1],"d":{"e":"f:"}}`, "#<array>\na:\n#<object>\nb:\n#<array>\nc:\n\t1\nd:\n\te:\n\t\t\"f:\""},
+		{`[[1,2],{"a":"_"},[[3]]]`, "_\n\t1\n\t2\n_\n\ta:\n\t\t\"_\"\n#<array>\n_\n\t#<array>\n\t_\n\t\t3"},
+		{`{"x y":" lead","#":"^","a:":"<>&"}`, "x y:\n\t\" lead\"\n\"#\":\n\t\"^\"\na::\n\t<>&"},
+		{`["+1","1+2i","::1"]`, "\"+1\"\n\"1+2i\"\n\"::1\""},
+		{`["1E+05","-0.50","1e05"]`, "\"1E+05\"\n\"-0.50\"\n\"1e05\""},
+	} {
+		list, err := FromJSON(strings.NewReader(testcase.json))
+		if err != nil {
+			t.Fatalf("testcase %d: %v", i, err)
+		}
+		if s := list.String(); s != testcase.teff {
+			t.Fatalf("testcase %d: expect \n%s\nbut got \n%s", i, testcase.teff, s)
+		}
+		parsed, err := core.Parse(strings.NewReader(testcase.teff))
+		if err != nil {
+			t.Fatalf("testcase %d: %v", i, err)
+		}
+		var buf bytes.Buffer
+		if err := ToJSON(&buf, parsed); err != nil {
+			t.Fatalf("testcase %d: %v", i, err)
+		}
+		if buf.String() != testcase.json {
+			t.Fatalf("testcase %d: expect \n%s\nbut got \n%s", i, testcase.json, buf.String())
+		}
+	}
+}
+
+func TestToJSONError(t *testing.T) {
+	for i, testcase := range []string{
+		"a:\n\t1\nb",
+		"^1",
+		"a\n\tb",
+		"\"a\n\"b\"",
+		"#<set>\n_",
+	} {
+		list, err := core.Parse(strings.NewReader(testcase))
+		if err != nil {
+			t.Fatalf("testcase %d: %v", i, err)
+		}
+		var buf bytes.Buffer
+		if err := ToJSON(&buf, list); err == nil {
+			t.Fatalf("testcase %d: expect error but got %s", i, buf.String())
+		}
+	}
+}
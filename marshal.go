@@ -2,11 +2,26 @@ package teff
 
 import (
 	"bytes"
-	"fmt"
 	"h12.io/teff/core"
 	"io"
 	"reflect"
-	"strconv"
+	"strings"
+)
+
+// Marshaler is implemented by types that encode themselves into a list, e.g.
+// the methods generated by teffgen.
+type Marshaler interface {
+	MarshalTEFF() (core.List, error)
+}
+
+// Unmarshaler is implemented by types that decode themselves from a list.
+type Unmarshaler interface {
+	UnmarshalTEFF(list core.List) error
+}
+
+var (
+	marshalerType   = reflect.TypeOf((*Marshaler)(nil)).Elem()
+	unmarshalerType = reflect.TypeOf((*Unmarshaler)(nil)).Elem()
 )
 
 func Marshal(v interface{}) ([]byte, error) {
@@ -22,27 +37,137 @@ func MarshalIndent(v interface{}, prefix, indent string) ([]byte, error) {
 	return w.Bytes(), nil
 }
 
+// Unmarshal decodes data into the value pointed to by v. It goes on decoding
+// the rest of data after a value fails, and returns UnmarshalErrors for all
+// the failed values.
 func Unmarshal(data []byte, v interface{}) error {
-	if string(data) == "nil" {
-		return nil
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return errInvalidUnmarshal
 	}
 	list, err := core.Parse(bytes.NewBuffer(data))
 	if err != nil {
 		return err
 	}
-	return unmarshalList(list, reflect.ValueOf(v))
+	return unmarshalList(newDecodeState(false), list, rv.Elem())
 }
 
+// UnmarshalStrict is like Unmarshal but returns an error on a key without a
+// matching struct field, a duplicate key, a node that is not consumed by v and
+// an annotation without a node.
+func UnmarshalStrict(data []byte, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return errInvalidUnmarshal
+	}
+	dec := NewDecoder(bytes.NewReader(data))
+	dec.Strict()
+	list, err := dec.readList()
+	if err != nil {
+		return err
+	}
+	return unmarshalList(newDecodeState(true), list, rv.Elem())
+}
+
+// Encoder writes TEFF values to an output stream.
 type Encoder struct {
-	w io.Writer
+	w           io.Writer
+	collapseNil bool
+	redact      []string
+	omit        []string
 }
 
 func NewEncoder(w io.Writer) *Encoder {
 	return &Encoder{w: w}
 }
 
+// CollapseNil makes the encoder write nil slices and maps as empty ones, so
+// that they are not distinguished when decoded. Nil pointers and interfaces
+// are still written as nil, and so is the list of a Marshaler other than the
+// methods generated by teffgen.
+func (enc *Encoder) CollapseNil() {
+	enc.collapseNil = true
+}
+
+// Redact makes the encoder write RedactedValue in place of the values at
+// paths, which are queries accepted by core.List.Select, e.g. "^..token". The
+// value of a key is replaced as a whole.
+func (enc *Encoder) Redact(paths ...string) {
+	enc.redact = append(enc.redact, paths...)
+}
+
+// Omit makes the encoder drop the nodes at paths, e.g. "^requests[*]id", after
+// the values at the paths of Redact are replaced. Encode fails if a value
+// replaced or dropped is that of a pointer referred to by a node that is kept.
+func (enc *Encoder) Omit(paths ...string) {
+	enc.omit = append(enc.omit, paths...)
+}
+
+// Encode writes v followed by a newline to the stream.
 func (enc *Encoder) Encode(v interface{}) error {
-	return nil
+	if err := enc.marshalIndent(v, "", "\t"); err != nil {
+		return err
+	}
+	_, err := io.WriteString(enc.w, "\n")
+	return err
+}
+
+// Decoder reads and decodes TEFF values from an input stream node by node.
+type Decoder struct {
+	r      *core.NodeReader
+	strict bool
+}
+
+func NewDecoder(r io.Reader) *Decoder {
+	return &Decoder{r: core.NewNodeReader(r)}
+}
+
+// Strict makes the decoder return an error on a key without a matching struct
+// field, a duplicate key, a node that is not consumed by the decoded value and
+// an annotation without a node. The lists passed to an Unmarshaler are not
+// checked unless it is generated by teffgen.
+func (dec *Decoder) Strict() {
+	dec.strict = true
+}
+
+// Token returns the next raw token in the input stream.
+func (dec *Decoder) Token() (core.Token, error) {
+	return dec.r.Token()
+}
+
+// More reports whether there is another node in the current list.
+func (dec *Decoder) More() bool {
+	return dec.r.More()
+}
+
+// Decode reads the next node in the current list and stores it in the value
+// pointed to by v.
+func (dec *Decoder) Decode(v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return errInvalidUnmarshal
+	}
+	node, err := dec.r.ReadNode()
+	if err != nil {
+		return err
+	}
+	return unmarshalNode(newDecodeState(dec.strict), *node, rv.Elem())
+}
+
+// readList reads the remaining nodes of the input stream.
+func (dec *Decoder) readList() (core.List, error) {
+	var list core.List
+	for dec.r.More() {
+		node, err := dec.r.ReadNode()
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, *node)
+	}
+	if _, err := dec.r.Token(); err != nil {
+		return nil, err
+	}
+	return list, nil
 }
 
 func (enc *Encoder) marshalIndent(v interface{}, prefix, indent string) error {
@@ -51,140 +176,79 @@ func (enc *Encoder) marshalIndent(v interface{}, prefix, indent string) error {
 	if v == nil {
 		list = core.List{core.Node{Value: "nil"}}
 	} else {
-		list, err = marshalList(reflect.ValueOf(v))
+		list, err = marshalList(&encodeState{collapseNil: enc.collapseNil}, reflect.ValueOf(v))
 		if err != nil {
 			return err
 		}
 	}
+	if list, err = mask(list, enc.redact, enc.omit); err != nil {
+		return err
+	}
 	return list.Marshal(enc.w, prefix, indent)
 }
 
-func marshalList(v reflect.Value) (core.List, error) {
-	switch v.Type().Kind() {
-	case reflect.Int, reflect.String:
-		node, err := marshalNode(v)
-		if err != nil {
-			return nil, err
-		}
-		return core.List{node}, nil
-	case reflect.Slice:
-		list := make(core.List, v.Len())
-		for i := 0; i < v.Len(); i++ {
-			node, err := marshalNode(v.Index(i))
-			if err != nil {
-				return nil, err
-			}
-			list[i] = node
-		}
-		return list, nil
-	case reflect.Ptr:
-		return marshalList(indirect(v))
-	}
-	return nil, fmt.Errorf("marshal unsupported")
-}
-
-func unmarshalList(list core.List, v reflect.Value) error {
-	switch v.Type().Kind() {
-	case reflect.Int, reflect.String:
-		return unmarshalNode(list[0], v)
-	case reflect.Slice:
-		for i, node := range list {
-			v.Set(reflect.Append(v, reflect.New(v.Type().Elem()).Elem()))
-			elem := v.Index(i)
-			if err := unmarshalNode(node, elem); err != nil {
-				return err
-			}
-		}
-		return nil
-	case reflect.Ptr:
-		return unmarshalList(list, allocIndirect(v))
+// MarshalList returns the list encoding v.
+func MarshalList(v interface{}) (core.List, error) {
+	if v == nil {
+		return core.List{{Value: "nil"}}, nil
 	}
-	return fmt.Errorf("unmarshal unsupported")
+	return marshalList(&encodeState{}, reflect.ValueOf(v))
 }
 
-func marshalNode(v reflect.Value) (core.Node, error) {
-	switch v.Type().Kind() {
-	case reflect.Int:
-		return core.Node{Value: fmt.Sprint(v.Interface())}, nil
-	case reflect.String:
-		s := v.Interface().(string)
-		if !strconv.CanBackquote(s) {
-			s = strconv.Quote(s)
-		}
-		return core.Node{Value: s}, nil
-	case reflect.Ptr:
-		return marshalNode(v.Elem())
+// UnmarshalList decodes list into the value pointed to by v.
+func UnmarshalList(list core.List, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return errInvalidUnmarshal
 	}
-	return core.Node{}, fmt.Errorf("marshal unsupported")
-
+	return unmarshalList(newDecodeState(false), list, rv.Elem())
 }
 
-func unmarshalNode(node core.Node, v reflect.Value) error {
-	switch v.Type().Kind() {
-	case reflect.Int:
-		i, err := strconv.Atoi(node.Value)
-		if err != nil {
-			return err
-		}
-		v.SetInt(int64(i))
-		return nil
-	case reflect.String:
-		s, err := strconv.Unquote(node.Value)
-		if err != nil {
-			s = node.Value
-		}
-		v.SetString(s)
-		return nil
-	case reflect.Ptr:
-		return unmarshalNode(node, allocIndirect(v))
+// UnmarshalListStrict is like UnmarshalList but returns an error on a key
+// without a matching struct field, a duplicate key and a node that is not
+// consumed by v.
+func UnmarshalListStrict(list core.List, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return errInvalidUnmarshal
 	}
-	return fmt.Errorf("unmarshal unsupported")
+	return unmarshalList(newDecodeState(true), list, rv.Elem())
 }
 
-func indirect(v reflect.Value) reflect.Value {
-	for v.Type().Kind() == reflect.Ptr && !v.IsNil() {
-		v = reflect.Indirect(v)
-	}
-	return v
+// IsEmpty reports whether v is omitted by the option omitempty.
+func IsEmpty(v interface{}) bool {
+	return v == nil || isEmptyValue(reflect.ValueOf(v))
 }
 
-func allocIndirect(v reflect.Value) reflect.Value {
-	for v.Type().Kind() == reflect.Ptr {
-		if v.IsNil() {
-			v.Set(reflect.New(v.Type().Elem()))
-		}
-		v = reflect.Indirect(v)
+// FormatString returns the value encoding s. s is written as a raw string
+// unless it is empty, starts with a space, "#", "^" or a double quote, contains
+// a character other than char_inline, or is "nil".
+func FormatString(s string) string {
+	if !core.IsValue(s) || s[0] == '"' || s == "nil" {
+		return core.Quote(s)
 	}
-	return v
-}
-
-type nodeRegistry struct {
-	node  *core.Node
-	label int
+	return s
 }
 
-type refRegister struct {
-	m      map[uintptr]nodeRegistry
-	serial int
+// FormatUntyped returns the value encoding s where the type of the value is
+// not known, e.g. a string of JSON, so s is also quoted if it could be read as
+// a value of another type, or as a key or an element parent.
+func FormatUntyped(s string) string {
+	if isAmbiguous(s) {
+		return core.Quote(s)
+	}
+	return FormatString(s)
 }
 
-func newRefRegister() *refRegister {
-	return &refRegister{
-		m:      make(map[uintptr]nodeRegistry),
-		serial: 1,
-	}
+func isAmbiguous(s string) bool {
+	return s == "_" || strings.HasSuffix(s, ":") || inferLabel(s) != "string"
 }
 
-func (r *refRegister) register(p uintptr, node *core.Node) int {
-	if reg, ok := r.m[p]; ok {
-		if reg.label == 0 {
-			reg.label = r.serial
-			reg.node.Annotations = append(reg.node.Annotations, "# ^"+strconv.Itoa(reg.label))
-			r.serial++
-			r.m[p] = reg
-		}
-		return reg.label
+// ParseString returns the string encoded by value. A value starting with a
+// double quote is an interpreted string, and any other value is a raw string.
+func ParseString(value string) (string, error) {
+	if strings.HasPrefix(value, `"`) {
+		return core.Unquote(value)
 	}
-	r.m[p] = nodeRegistry{node, 0}
-	return 0
+	return value, nil
 }
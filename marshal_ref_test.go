@@ -0,0 +1,306 @@
+package teff
+
+import (
+	"bytes"
+	"fmt"
+	"h12.io/teff/core"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+// refMarshalList and refUnmarshalList are the per-value reflection path that
+// the compiled codecs replaced, which inspects the type of every value it
+// encodes or decodes. They are kept as the reference that the codecs are
+// checked and benchmarked against, for the values they support.
+func refMarshalList(v reflect.Value) (core.List, error) {
+	if m, ok := refMarshaler(v); ok {
+		return m.MarshalTEFF()
+	}
+	if _, ok := extensions[v.Type()]; !ok {
+		switch v.Type().Kind() {
+		case reflect.Slice:
+			list := make(core.List, v.Len())
+			for i := 0; i < v.Len(); i++ {
+				node, err := refMarshalNode(v.Index(i))
+				if err != nil {
+					return nil, err
+				}
+				list[i] = node
+			}
+			return list, nil
+		case reflect.Struct:
+			return refMarshalStruct(v)
+		case reflect.Ptr:
+			if v.IsNil() {
+				return core.List{{Value: "nil"}}, nil
+			}
+			return refMarshalList(v.Elem())
+		}
+	}
+	node, err := refMarshalNode(v)
+	if err != nil {
+		return nil, err
+	}
+	return core.List{node}, nil
+}
+
+func refUnmarshalList(list core.List, v reflect.Value) error {
+	if u, ok := refUnmarshaler(v); ok {
+		return u.UnmarshalTEFF(list)
+	}
+	if _, ok := extensions[v.Type()]; !ok {
+		switch v.Type().Kind() {
+		case reflect.Slice:
+			for _, node := range list {
+				v.Set(reflect.Append(v, reflect.New(v.Type().Elem()).Elem()))
+				if err := refUnmarshalNode(node, v.Index(v.Len()-1)); err != nil {
+					return err
+				}
+			}
+			return nil
+		case reflect.Struct:
+			return refUnmarshalStruct(list, v)
+		case reflect.Ptr:
+			if len(list) == 1 && isNil(list[0]) {
+				v.Set(reflect.Zero(v.Type()))
+				return nil
+			}
+			return refUnmarshalList(list, refAllocIndirect(v))
+		}
+	}
+	if len(list) != 1 {
+		return fmt.Errorf("unmarshal: expect a single value but got %d nodes", len(list))
+	}
+	return refUnmarshalNode(list[0], v)
+}
+
+func refMarshalStruct(v reflect.Value) (core.List, error) {
+	list := core.List{}
+	for _, f := range StructFields(v.Type()) {
+		fv := v.FieldByIndex(f.Index)
+		if f.OmitEmpty && isEmptyValue(fv) {
+			continue
+		}
+		children, err := refMarshalList(fv)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, core.Node{Value: f.Name + ":", List: children})
+	}
+	return list, nil
+}
+
+func refUnmarshalStruct(list core.List, v reflect.Value) error {
+	fields := StructFields(v.Type())
+	for _, node := range list {
+		for _, f := range fields {
+			if node.Value == f.Name+":" {
+				if err := refUnmarshalList(node.List, v.FieldByIndex(f.Index)); err != nil {
+					return err
+				}
+				break
+			}
+		}
+	}
+	return nil
+}
+
+func refMarshalNode(v reflect.Value) (core.Node, error) {
+	if m, ok := refMarshaler(v); ok {
+		list, err := m.MarshalTEFF()
+		if err != nil {
+			return core.Node{}, err
+		}
+		if len(list) == 1 && !isKey(list[0]) {
+			return list[0], nil
+		}
+		return core.Node{Value: "_", List: list}, nil
+	}
+	if ext, ok := extensions[v.Type()]; ok {
+		return core.Node{Value: ext.marshal(v)}, nil
+	}
+	switch v.Type().Kind() {
+	case reflect.Bool:
+		return core.Node{Value: strconv.FormatBool(v.Bool())}, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return core.Node{Value: strconv.FormatInt(v.Int(), 10)}, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return core.Node{Value: strconv.FormatUint(v.Uint(), 10)}, nil
+	case reflect.Float32, reflect.Float64:
+		return core.Node{Value: strconv.FormatFloat(v.Float(), 'g', -1, v.Type().Bits())}, nil
+	case reflect.String:
+		return core.Node{Value: FormatString(v.String())}, nil
+	case reflect.Struct:
+		list, err := refMarshalStruct(v)
+		if err != nil {
+			return core.Node{}, err
+		}
+		return core.Node{Value: "_", List: list}, nil
+	case reflect.Ptr:
+		if v.IsNil() {
+			return core.Node{Value: "nil"}, nil
+		}
+		return refMarshalNode(v.Elem())
+	}
+	return core.Node{}, errMarshalUnsupported
+}
+
+func refUnmarshalNode(node core.Node, v reflect.Value) error {
+	if u, ok := refUnmarshaler(v); ok {
+		if node.Value == "_" {
+			return u.UnmarshalTEFF(node.List)
+		}
+		return u.UnmarshalTEFF(core.List{node})
+	}
+	if ext, ok := extensions[v.Type()]; ok {
+		return ext.unmarshal(node.Value, v)
+	}
+	switch v.Type().Kind() {
+	case reflect.Bool:
+		b, err := strconv.ParseBool(node.Value)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+		return nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(node.Value, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(i)
+		return nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		u, err := strconv.ParseUint(node.Value, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(u)
+		return nil
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(node.Value, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+		return nil
+	case reflect.String:
+		s, err := ParseString(node.Value)
+		if err != nil {
+			return err
+		}
+		v.SetString(s)
+		return nil
+	case reflect.Struct:
+		return refUnmarshalStruct(node.List, v)
+	case reflect.Ptr:
+		if isNil(node) {
+			v.Set(reflect.Zero(v.Type()))
+			return nil
+		}
+		return refUnmarshalNode(node, refAllocIndirect(v))
+	}
+	return errUnmarshalUnsupported
+}
+
+// refMarshaler returns the Marshaler of a non-pointer value v.
+func refMarshaler(v reflect.Value) (Marshaler, bool) {
+	switch {
+	case v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface:
+	case v.Type().Implements(marshalerType):
+		return v.Interface().(Marshaler), true
+	case v.CanAddr() && v.Addr().Type().Implements(marshalerType):
+		return v.Addr().Interface().(Marshaler), true
+	}
+	return nil, false
+}
+
+// refUnmarshaler returns the Unmarshaler of an addressable non-pointer value v.
+func refUnmarshaler(v reflect.Value) (Unmarshaler, bool) {
+	if v.Kind() != reflect.Ptr && v.CanAddr() && v.Addr().Type().Implements(unmarshalerType) {
+		return v.Addr().Interface().(Unmarshaler), true
+	}
+	return nil, false
+}
+
+func refAllocIndirect(v reflect.Value) reflect.Value {
+	for v.Type().Kind() == reflect.Ptr {
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		v = reflect.Indirect(v)
+	}
+	return v
+}
+
+func refMarshal(v interface{}) ([]byte, error) {
+	list, err := refMarshalList(reflect.ValueOf(v))
+	if err != nil {
+		return nil, err
+	}
+	var w bytes.Buffer
+	if err := list.Marshal(&w, "", "\t"); err != nil {
+		return nil, err
+	}
+	return w.Bytes(), nil
+}
+
+func refUnmarshal(data []byte, v interface{}) error {
+	list, err := core.Parse(bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	return refUnmarshalList(list, reflect.ValueOf(v).Elem())
+}
+
+func TestMarshalReference(t *testing.T) {
+	structs := benchStructs(100)
+	expected, err := refMarshal(structs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	actual, err := Marshal(structs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(actual) != string(expected) {
+		t.Fatalf("expect\n%s\nbut got\n%s", expected, actual)
+	}
+	var refStructs, codecStructs []benchStruct
+	if err := refUnmarshal(expected, &refStructs); err != nil {
+		t.Fatal(err)
+	}
+	if err := Unmarshal(expected, &codecStructs); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(codecStructs, refStructs) || !reflect.DeepEqual(codecStructs, structs) {
+		t.Fatalf("expect\n%v\nbut got\n%v", refStructs, codecStructs)
+	}
+}
+
+func BenchmarkMarshalStructsReference(b *testing.B) {
+	structs := benchStructs(10000)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := refMarshal(structs); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkUnmarshalStructsReference(b *testing.B) {
+	data, err := Marshal(benchStructs(10000))
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		var structs []benchStruct
+		if err := refUnmarshal(data, &structs); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
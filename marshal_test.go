@@ -1,9 +1,16 @@
 package teff
 
 import (
+	"bytes"
 	"fmt"
+	"h12.io/teff/core"
+	"io"
+	"net"
+	"net/url"
 	"reflect"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestMarshal(t *testing.T) {
@@ -56,6 +63,184 @@ func TestMarshal(t *testing.T) {
 	}
 }
 
+func TestFormatString(t *testing.T) {
+	for i, testcase := range []struct {
+		s, typed, untyped string
+	}{
+		{"a b", "a b", "a b"},
+		{"a\tb ", "a\tb ", "a\tb "},
+		{"", `""`, `""`},
+		{" a", `" a"`, `" a"`},
+		{"\ta", `"\ta"`, `"\ta"`},
+		{"#a", `"#a"`, `"#a"`},
+		{"^a", `"^a"`, `"^a"`},
+		{`"a"`, `"\"a\""`, `"\"a\""`},
+		{"`a`", "`a`", "`a`"},
+		{"a\nb", `"a\nb"`, `"a\nb"`},
+		{"nil", `"nil"`, `"nil"`},
+		{"true", "true", `"true"`},
+		{"12", "12", `"12"`},
+		{"-1.5e3", "-1.5e3", `"-1.5e3"`},
+		{"0x1f", "0x1f", "0x1f"},
+		{"1-2.5i", "1-2.5i", `"1-2.5i"`},
+		{"2015-01-02T03:04:05Z", "2015-01-02T03:04:05Z", `"2015-01-02T03:04:05Z"`},
+		{"10.0.0.1", "10.0.0.1", `"10.0.0.1"`},
+		{"::1", "::1", `"::1"`},
+		{"a:", "a:", `"a:"`},
+		{"_", "_", `"_"`},
+	} {
+		if typed := FormatString(testcase.s); typed != testcase.typed {
+			t.Fatalf("testcase %d: expect %s but got %s", i, testcase.typed, typed)
+		}
+		if untyped := FormatUntyped(testcase.s); untyped != testcase.untyped {
+			t.Fatalf("testcase %d: expect %s but got %s", i, testcase.untyped, untyped)
+		}
+		for _, value := range []string{testcase.typed, testcase.untyped} {
+			if s, err := ParseString(value); err != nil || s != testcase.s {
+				t.Fatalf("testcase %d: expect %q but got %q, %v", i, testcase.s, s, err)
+			}
+		}
+	}
+}
+
+func TestMarshalString(t *testing.T) {
+	for i, testcase := range []struct {
+		value interface{}
+		text  string
+	}{
+		{[]string{"", "#a", "^b", " c", "nil", "true"}, "\"\"\n\"#a\"\n\"^b\"\n\" c\"\n\"nil\"\ntrue"},
+		{[]interface{}{"a", "true", true, "1", 1, nil}, "a\n\"true\"\ntrue\n\"1\"\n1\nnil"},
+		{struct{ S *string }{ns("nil")}, "S:\n\t\"nil\""},
+	} {
+		buf, err := Marshal(testcase.value)
+		if err != nil {
+			t.Fatalf("testcase %d: %v", i, err)
+		}
+		if string(buf) != testcase.text {
+			t.Fatalf("testcase %d: expect \n%s\n    but got \n%s", i, testcase.text, buf)
+		}
+	}
+	var ss []string
+	if err := Unmarshal([]byte("\"\"\n\"#a\"\n\"^b\"\n\" c\"\n\"nil\"\ntrue"), &ss); err != nil {
+		t.Fatal(err)
+	}
+	if expected := []string{"", "#a", "^b", " c", "nil", "true"}; !reflect.DeepEqual(ss, expected) {
+		t.Fatalf("expect %q but got %q", expected, ss)
+	}
+	var v struct{ S *string }
+	if err := Unmarshal([]byte("S:\n\t\"nil\""), &v); err != nil || v.S == nil || *v.S != "nil" {
+		t.Fatalf("expect \"nil\" but got %v, %v", v.S, err)
+	}
+}
+
+func TestMarshalNested(t *testing.T) {
+	for i, testcase := range []struct {
+		value interface{}
+		text  string
+	}{
+		{[][]int{{1, 2}, nil, {}, {3}}, "_\n\t1\n\t2\nnil\n_\n_\n\t3"},
+		{[][][]string{{{"a"}, {"b", "c"}}}, "_\n\t_\n\t\ta\n\t_\n\t\tb\n\t\tc"},
+		{[2][3]int{{1, 2, 3}, {4, 5, 6}}, "_\n\t1\n\t2\n\t3\n_\n\t4\n\t5\n\t6"},
+		{[]*[2]bool{{true, false}, nil}, "_\n\ttrue\n\tfalse\nnil"},
+		{struct{ M [][]int }{[][]int{{1}}}, "M:\n\t_\n\t\t1"},
+	} {
+		buf, err := Marshal(testcase.value)
+		if err != nil {
+			t.Fatalf("testcase %d: %v", i, err)
+		}
+		if string(buf) != testcase.text {
+			t.Fatalf("testcase %d: expect \n%s\n    but got \n%s", i, testcase.text, buf)
+		}
+		newValue := newValueOf(testcase.value)
+		if err := UnmarshalStrict(buf, newValue); err != nil {
+			t.Fatalf("testcase %d: %v", i, err)
+		}
+		if actual := reflect.ValueOf(newValue).Elem().Interface(); !reflect.DeepEqual(actual, testcase.value) {
+			t.Fatalf("testcase %d: expect %#v but got %#v", i, testcase.value, actual)
+		}
+	}
+	for i, testcase := range []struct {
+		text string
+		v    interface{}
+		err  string
+	}{
+		{"1\n2", &[3]int{}, `unmarshal: line 1: ^: cannot decode into [3]int: expect 3 elements but got 2`},
+		{"X:\n\t_\n\t\t1\n\t_", &struct{ X [][1]int }{}, `unmarshal: line 4: ^X[1]: cannot decode into [1]int: expect 1 elements but got 0`},
+		{"1", &[][]int{}, `unmarshal: line 1: ^[0]: cannot decode "1" into []int: expect _`},
+	} {
+		err := Unmarshal([]byte(testcase.text), testcase.v)
+		if err == nil || err.Error() != testcase.err {
+			t.Fatalf("testcase %d: expect error %q but got %v", i, testcase.err, err)
+		}
+	}
+}
+
+type testNil struct {
+	S []int
+	M map[string]int
+	P *int
+	I interface{}
+}
+
+func TestMarshalNil(t *testing.T) {
+	for i, testcase := range []struct {
+		value interface{}
+		text  string
+	}{
+		{[]int(nil), "nil"},
+		{[]int{}, ""},
+		{map[string]int(nil), "nil"},
+		{map[string]int{}, ""},
+		{map[string]int{"b": 1, "a": 2, "": 3}, "\"\":\n\t3\na:\n\t2\nb:\n\t1"},
+		{map[int][]string{2: nil, 1: {}}, "1:\n2:\n\tnil"},
+		{map[int]int{10: 1, 9: 2, -1: 3}, "-1:\n\t3\n9:\n\t2\n10:\n\t1"},
+		{map[float64]bool{10: true, 2.5: false, -1e-9: true}, "-1e-09:\n\ttrue\n2.5:\n\tfalse\n10:\n\ttrue"},
+		{map[bool]int{true: 1, false: 0}, "false:\n\t0\ntrue:\n\t1"},
+		{[]map[string]bool{nil, {}, {"x": true}}, "nil\n_\n_\n\tx:\n\t\ttrue"},
+		{testNil{}, "S:\n\tnil\nM:\n\tnil\nP:\n\tnil\nI:\n\tnil"},
+		{testNil{S: []int{}, M: map[string]int{}}, "S:\nM:\nP:\n\tnil\nI:\n\tnil"},
+		{[]*int{nil, new(int)}, "nil\n0"},
+		{[]*int{nil}, "#<list>\n_\n\tnil"},
+		{[][]int{nil}, "#<list>\n_\n\tnil"},
+		{[]interface{}{nil}, "#<list>\n_\n\tnil"},
+		{map[string][]*int{"a": {nil}}, "a:\n\t#<list>\n\t_\n\t\tnil"},
+		{[][]*int{{nil}}, "_\n\t#<list>\n\t_\n\t\tnil"},
+	} {
+		buf, err := Marshal(testcase.value)
+		if err != nil {
+			t.Fatalf("testcase %d: %v", i, err)
+		}
+		if string(buf) != testcase.text {
+			t.Fatalf("testcase %d: expect \n%s\n    but got \n%s", i, testcase.text, buf)
+		}
+		newValue := newValueOf(testcase.value)
+		if err := UnmarshalStrict(buf, newValue); err != nil {
+			t.Fatalf("testcase %d: %v", i, err)
+		}
+		if actual := reflect.ValueOf(newValue).Elem().Interface(); !reflect.DeepEqual(actual, testcase.value) {
+			t.Fatalf("testcase %d: expect %#v but got %#v", i, testcase.value, actual)
+		}
+	}
+	var n int
+	if err := Unmarshal([]byte("nil"), &n); err == nil {
+		t.Fatal("expect error of nil for an int but got nil")
+	}
+}
+
+func TestEncoderCollapseNil(t *testing.T) {
+	var buf bytes.Buffer
+	enc := NewEncoder(&buf)
+	enc.CollapseNil()
+	for _, v := range []interface{}{testNil{}, [][]int{nil}} {
+		if err := enc.Encode(v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if expected := "S:\nM:\nP:\n\tnil\nI:\n\tnil\n_\n"; buf.String() != expected {
+		t.Fatalf("expect \n%s\n    but got \n%s", expected, buf.String())
+	}
+}
+
 func TestAlloc(t *testing.T) {
 	{
 		var p *int
@@ -79,7 +264,7 @@ func TestAlloc(t *testing.T) {
 
 func newValueOf(v interface{}) interface{} {
 	if v == nil {
-		return nil
+		return new(interface{})
 	}
 	return reflect.New(reflect.TypeOf(v)).Interface()
 }
@@ -89,3 +274,218 @@ var p = fmt.Println
 func ns(s string) *string {
 	return &s
 }
+
+func TestDecoder(t *testing.T) {
+	dec := NewDecoder(strings.NewReader("1\n2\n3"))
+	var is []int
+	for dec.More() {
+		var i int
+		if err := dec.Decode(&i); err != nil {
+			t.Fatal(err)
+		}
+		is = append(is, i)
+	}
+	if !reflect.DeepEqual(is, []int{1, 2, 3}) {
+		t.Fatalf("expect [1 2 3] but got %v", is)
+	}
+	if tok, err := dec.Token(); err != nil || tok.Type != core.EOF {
+		t.Fatalf("expect EOF but got %v, %v", tok, err)
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		t.Fatalf("expect io.EOF but got %v", err)
+	}
+}
+
+func TestDecoderToken(t *testing.T) {
+	dec := NewDecoder(strings.NewReader("records:\n\ta\n\t\"b\"\nx"))
+	for _, typ := range []core.TokenType{core.LineValue, core.Indent} {
+		if tok, err := dec.Token(); err != nil || tok.Type != typ {
+			t.Fatalf("expect token type %d but got %v, %v", typ, tok, err)
+		}
+	}
+	var ss []string
+	for dec.More() {
+		var s string
+		if err := dec.Decode(&s); err != nil {
+			t.Fatal(err)
+		}
+		ss = append(ss, s)
+	}
+	if !reflect.DeepEqual(ss, []string{"a", "b"}) {
+		t.Fatalf("expect [a b] but got %v", ss)
+	}
+}
+
+type testStruct struct {
+	Name    string `teff:"name"`
+	Port    uint16
+	Ratio   float64 `teff:",omitempty"`
+	On      bool
+	Tags    []string `teff:"tags,omitempty"`
+	Inner   *testInner
+	Skipped int `teff:"-"`
+	private int
+}
+
+type testInner struct {
+	At   time.Time
+	IP   net.IP
+	Home url.URL
+}
+
+func TestMarshalStruct(t *testing.T) {
+	at := time.Date(2015, 1, 2, 3, 4, 5, 6, time.UTC)
+	home, _ := url.Parse("http://h12.io/teff?a=1")
+	for i, testcase := range []struct {
+		value interface{}
+		text  string
+	}{
+		{testStruct{Name: "a", Port: 80, On: true}, "name:\n\ta\nPort:\n\t80\nOn:\n\ttrue\nInner:\n\tnil"},
+		{&testStruct{
+			Name:  "b c",
+			Ratio: 0.5,
+			Tags:  []string{"x", "y"},
+			Inner: &testInner{At: at, IP: net.ParseIP("10.0.0.1"), Home: *home},
+		}, "name:\n\tb c\nPort:\n\t0\nRatio:\n\t0.5\nOn:\n\tfalse\ntags:\n\tx\n\ty\nInner:\n\tAt:\n\t\t2015-01-02T03:04:05.000000006Z\n\tIP:\n\t\t10.0.0.1\n\tHome:\n\t\thttp://h12.io/teff?a=1"},
+		{[]testInner{{At: at, IP: net.ParseIP("::1"), Home: *home}}, "_\n\tAt:\n\t\t2015-01-02T03:04:05.000000006Z\n\tIP:\n\t\t::1\n\tHome:\n\t\thttp://h12.io/teff?a=1"},
+		{testInner{}, "At:\n\t0001-01-01T00:00:00Z\nIP:\n\tnil\nHome:\n\t\"\""},
+		{testInner{At: at, Home: url.URL{Fragment: "x"}}, "At:\n\t2015-01-02T03:04:05.000000006Z\nIP:\n\tnil\nHome:\n\t\"#x\""},
+	} {
+		buf, err := Marshal(testcase.value)
+		if err != nil {
+			t.Fatalf("testcase %d: %v", i, err)
+		}
+		if string(buf) != testcase.text {
+			t.Fatalf("testcase %d: expect \n%s\n    but got \n%s", i, testcase.text, string(buf))
+		}
+		newValue := newValueOf(testcase.value)
+		if err := Unmarshal(buf, newValue); err != nil {
+			t.Fatalf("testcase %d: %v", i, err)
+		}
+		if actual := reflect.ValueOf(newValue).Elem().Interface(); !reflect.DeepEqual(actual, testcase.value) {
+			t.Fatalf("testcase %d: expect %#v but got %#v", i, testcase.value, actual)
+		}
+	}
+}
+
+func TestUnmarshalStrict(t *testing.T) {
+	var v testStruct
+	if err := UnmarshalStrict([]byte("# a\nname:\n\ta\ntags:\n\tx\nInner:\n\tIP:\n\t\t::1"), &v); err != nil {
+		t.Fatal(err)
+	}
+	for i, testcase := range []struct {
+		text string
+		v    interface{}
+		err  string
+	}{
+		{"name:\n\ta\nport:\n\t1", &testStruct{}, `unmarshal: line 3: ^port: cannot decode "port:" into teff.testStruct: unknown key "port"`},
+		{"name:\n\ta\nname:\n\tb", &testStruct{}, `unmarshal: line 3: ^name: cannot decode "name:" into teff.testStruct: duplicate key "name"`},
+		{"name:\n\ta\nb", &testStruct{}, `unmarshal: line 3: ^: cannot decode "b" into teff.testStruct: expect a key`},
+		{"Inner:\n\tHome:\n\t\tx\n\tX:\n\t\t1", &testStruct{}, `unmarshal: line 4: ^Inner:X: cannot decode "X:" into teff.testInner: unknown key "X"`},
+		{"name:\n\ta\n\t\tb", &testStruct{}, `unmarshal: line 3: ^name: cannot decode "b" into string: unexpected child`},
+		{"name:\n\t^a", &testStruct{}, `unmarshal: line 2: ^name: cannot decode "^a" into string: unexpected reference`},
+		{"1\n2", new(int), `unmarshal: line 2: ^: cannot decode "2" into int: unexpected node after a single value`},
+		{"x\n\tk:\n\t\t1", &[]testInner{}, `unmarshal: line 1: ^[0]: cannot decode "x" into teff.testInner: expect _`},
+		{"1\n# a", new(int), "line 2: syntax error, annotation without a node"},
+	} {
+		err := UnmarshalStrict([]byte(testcase.text), testcase.v)
+		if err == nil || err.Error() != testcase.err {
+			t.Fatalf("testcase %d: expect error %q but got %v", i, testcase.err, err)
+		}
+	}
+}
+
+func TestUnmarshalListInvalid(t *testing.T) {
+	list := core.List{{Value: "1"}}
+	for i, v := range []interface{}{nil, 1, (*int)(nil)} {
+		if err := UnmarshalList(list, v); err != errInvalidUnmarshal {
+			t.Fatalf("testcase %d: expect %v but got %v", i, errInvalidUnmarshal, err)
+		}
+		if err := UnmarshalListStrict(list, v); err != errInvalidUnmarshal {
+			t.Fatalf("testcase %d: expect %v but got %v", i, errInvalidUnmarshal, err)
+		}
+	}
+}
+
+func TestDecoderStrict(t *testing.T) {
+	dec := NewDecoder(strings.NewReader("_\n\tAt:\n\t\t2015-01-02T03:04:05Z\n_\n\tAt:\n\t\t2015-01-02T03:04:05Z\n\tPort:\n\t\t1"))
+	dec.Strict()
+	var v testInner
+	if err := dec.Decode(&v); err != nil {
+		t.Fatal(err)
+	}
+	expected := `unmarshal: line 7: ^Port: cannot decode "Port:" into teff.testInner: unknown key "Port"`
+	if err := dec.Decode(&v); err == nil || err.Error() != expected {
+		t.Fatalf("expect error %q but got %v", expected, err)
+	}
+}
+
+type benchStruct struct {
+	Name  string `teff:"name"`
+	Port  int
+	Ratio float64
+	On    bool `teff:",omitempty"`
+	Tags  []string
+	Inner *testInner `teff:",omitempty"`
+}
+
+func benchStructs(n int) []benchStruct {
+	at := time.Date(2015, 1, 2, 3, 4, 5, 6, time.UTC)
+	structs := make([]benchStruct, n)
+	for i := range structs {
+		structs[i] = benchStruct{Name: "host", Port: i, Ratio: 0.5, On: i%2 == 0, Tags: []string{"a", "b"}}
+		if i%10 == 0 {
+			structs[i].Inner = &testInner{At: at, IP: net.ParseIP("10.0.0.1"), Home: url.URL{Scheme: "http", Host: "h12.io"}}
+		}
+	}
+	return structs
+}
+
+func BenchmarkMarshalStructs(b *testing.B) {
+	structs := benchStructs(10000)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := Marshal(structs); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkUnmarshalStructs(b *testing.B) {
+	data, err := Marshal(benchStructs(10000))
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		var structs []benchStruct
+		if err := Unmarshal(data, &structs); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func FuzzUnmarshal(f *testing.F) {
+	for _, text := range []string{"1", "a\nb", "nil", "name:\n\ta\nPort:\n\t80\nInner:\n\tIP:\n\t\t::1"} {
+		f.Add(text)
+	}
+	f.Fuzz(func(t *testing.T, s string) {
+		for _, v := range []interface{}{
+			new(int),
+			new(*string),
+			new([]*int8),
+			new(testStruct),
+			new([]testInner),
+			new(testTree),
+		} {
+			if err := Unmarshal([]byte(s), v); err != nil {
+				continue
+			}
+			if _, err := Marshal(v); err != nil {
+				t.Fatalf("marshal %#v unmarshaled from %q: %v", v, s, err)
+			}
+		}
+	})
+}
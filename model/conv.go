@@ -2,6 +2,7 @@ package model
 
 import (
 	"fmt"
+	"math"
 	"reflect"
 )
 
@@ -23,7 +24,9 @@ func (m *maker) toNode(v reflect.Value) (*Node, error) {
 	var c C
 	var err error
 	switch v.Type().Kind() {
-	case reflect.Int, reflect.String:
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64, reflect.String:
 		c, err = m.toValue(v)
 	case reflect.Slice, reflect.Array:
 		c, err = m.toArray(v)
@@ -40,7 +43,9 @@ func (m *maker) toNode(v reflect.Value) (*Node, error) {
 
 func (f *filler) nodeTo(node *Node, v reflect.Value) error {
 	switch v.Type().Kind() {
-	case reflect.Int, reflect.String:
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64, reflect.String:
 		if value, ok := node.C.(Value); ok {
 			return f.valueTo(value, v)
 		}
@@ -81,6 +86,12 @@ func (m *maker) toValue(v reflect.Value) (Value, error) {
 	switch v.Type().Kind() {
 	case reflect.Int:
 		return Value{int(v.Int())}, nil
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return Value{v.Int()}, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return Value{v.Uint()}, nil
+	case reflect.Float32, reflect.Float64:
+		return Value{v.Float()}, nil
 	case reflect.String:
 		return Value{v.String()}, nil
 	}
@@ -89,13 +100,103 @@ func (m *maker) toValue(v reflect.Value) (Value, error) {
 
 func (f *filler) valueTo(value Value, v reflect.Value) error {
 	switch v.Type().Kind() {
-	case reflect.Int, reflect.String:
-		v.Set(reflect.ValueOf(value.V))
-		return nil
 	case reflect.Ptr:
 		return f.valueToPtr(value, v)
 	}
-	return fmt.Errorf("filler.valueTo: unsupported type: %v", v.Type())
+	if value.V == nil {
+		return fmt.Errorf("filler.valueTo: cannot fill %v with nil", v.Type())
+	}
+	return setConverted(reflect.ValueOf(value.V), v)
+}
+
+// setConverted sets src to dst, converting between integer, unsigned integer
+// and float kinds of any width, and between named types sharing the same
+// underlying kind. It returns an error when the value does not fit dst.
+func setConverted(src, dst reflect.Value) error {
+	switch dst.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		var i int64
+		switch src.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			i = src.Int()
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+			u := src.Uint()
+			if u > math.MaxInt64 {
+				return overflowError(src, dst)
+			}
+			i = int64(u)
+		case reflect.Float32, reflect.Float64:
+			fl := src.Float()
+			if fl != math.Trunc(fl) || fl < math.MinInt64 || fl >= math.MaxInt64 {
+				return overflowError(src, dst)
+			}
+			i = int64(fl)
+		default:
+			return mismatchError(src, dst)
+		}
+		if dst.OverflowInt(i) {
+			return overflowError(src, dst)
+		}
+		dst.SetInt(i)
+		return nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		var u uint64
+		switch src.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			i := src.Int()
+			if i < 0 {
+				return overflowError(src, dst)
+			}
+			u = uint64(i)
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+			u = src.Uint()
+		case reflect.Float32, reflect.Float64:
+			fl := src.Float()
+			if fl != math.Trunc(fl) || fl < 0 || fl >= math.MaxUint64 {
+				return overflowError(src, dst)
+			}
+			u = uint64(fl)
+		default:
+			return mismatchError(src, dst)
+		}
+		if dst.OverflowUint(u) {
+			return overflowError(src, dst)
+		}
+		dst.SetUint(u)
+		return nil
+	case reflect.Float32, reflect.Float64:
+		var fl float64
+		switch src.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			fl = float64(src.Int())
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+			fl = float64(src.Uint())
+		case reflect.Float32, reflect.Float64:
+			fl = src.Float()
+		default:
+			return mismatchError(src, dst)
+		}
+		if dst.OverflowFloat(fl) {
+			return overflowError(src, dst)
+		}
+		dst.SetFloat(fl)
+		return nil
+	case reflect.String:
+		if src.Kind() != reflect.String {
+			return mismatchError(src, dst)
+		}
+		dst.SetString(src.String())
+		return nil
+	}
+	return fmt.Errorf("filler.valueTo: unsupported type: %v", dst.Type())
+}
+
+func overflowError(src, dst reflect.Value) error {
+	return fmt.Errorf("filler.valueTo: value %v (%v) overflows %v", src.Interface(), src.Type(), dst.Type())
+}
+
+func mismatchError(src, dst reflect.Value) error {
+	return fmt.Errorf("filler.valueTo: cannot fill %v with %v (%v)", dst.Type(), src.Interface(), src.Type())
 }
 
 func allocIndirect(v reflect.Value) reflect.Value {
@@ -2,6 +2,7 @@ package model
 
 import (
 	"fmt"
+	"math"
 	"reflect"
 	"testing"
 )
@@ -266,3 +267,55 @@ func array(n ...*Node) *Node {
 	}
 	return &Node{C: Array(n)}
 }
+
+type (
+	myInt    int64
+	myString string
+)
+
+func TestFillConvert(t *testing.T) {
+	for i, testcase := range []struct {
+		n        *Node
+		v        interface{}
+		expected interface{}
+	}{
+		{value(1), new(int64), int64(1)},
+		{value(1), new(int8), int8(1)},
+		{value(1), new(uint16), uint16(1)},
+		{value(1), new(float32), float32(1)},
+		{value(int64(-3)), new(int), -3},
+		{value(uint64(3)), new(int32), int32(3)},
+		{value(2.0), new(int), 2},
+		{value(1.5), new(float64), 1.5},
+		{value(7), new(myInt), myInt(7)},
+		{value("a"), new(myString), myString("a")},
+		{value(myString("a")), new(string), "a"},
+		{array(value(1), value(int64(2))), new([]int8), []int8{1, 2}},
+	} {
+		if err := testcase.n.Fill(testcase.v); err != nil {
+			t.Fatalf("testcase %d: Fill: %v", i, err)
+		}
+		if actual := reflect.ValueOf(testcase.v).Elem().Interface(); !reflect.DeepEqual(actual, testcase.expected) {
+			t.Fatalf("testcase %d: expect %#v got %#v", i, testcase.expected, actual)
+		}
+	}
+}
+
+func TestFillConvertError(t *testing.T) {
+	for i, testcase := range []struct {
+		n *Node
+		v interface{}
+	}{
+		{value(128), new(int8)},
+		{value(-1), new(uint)},
+		{value(uint64(math.MaxUint64)), new(int64)},
+		{value(1.5), new(int)},
+		{value(1e300), new(float32)},
+		{value("1"), new(int)},
+		{value(1), new(string)},
+	} {
+		if err := testcase.n.Fill(testcase.v); err == nil {
+			t.Fatalf("testcase %d: expect error but got nil", i)
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package teff
+
+import (
+	"fmt"
+	"h12.io/teff/core"
+)
+
+// RedactedValue is the placeholder written in place of a redacted value.
+const RedactedValue = "<redacted>"
+
+// Redacted holds a value, e.g. a password, that is always encoded as
+// RedactedValue. It is decoded as the zero value from the placeholder, and as
+// the held value from any other list.
+type Redacted[T any] struct {
+	Value T
+}
+
+func (r Redacted[T]) MarshalTEFF() (core.List, error) {
+	return core.List{{Value: RedactedValue}}, nil
+}
+
+func (r *Redacted[T]) UnmarshalTEFF(list core.List) error {
+	if isRedacted(list) {
+		var zero T
+		r.Value = zero
+		return nil
+	}
+	return UnmarshalList(list, &r.Value)
+}
+
+// String returns RedactedValue so that the held value is not printed either.
+func (r Redacted[T]) String() string {
+	return RedactedValue
+}
+
+func isRedacted(list core.List) bool {
+	return len(list) == 1 && !list[0].IsReference && list[0].Value == RedactedValue && len(list[0].List) == 0
+}
+
+// mask replaces the values at the queries redact with RedactedValue, and then
+// removes the nodes at the queries omit. It returns an error if a value
+// replaced or removed is labeled and referred to by a node that is kept, as
+// the reference would then be left without its value.
+func mask(list core.List, redact, omit []string) (core.List, error) {
+	masked := make(map[string]bool) // labels of the values replaced or removed
+	for _, query := range redact {
+		nodes, err := list.Select(query)
+		if err != nil {
+			return nil, err
+		}
+		for _, node := range nodes {
+			collectLabels(node, masked)
+			node.SetTypeLabel("")
+			node.SetRefLabel("")
+			if isKey(*node) {
+				node.List = core.List{{Value: RedactedValue}}
+			} else {
+				node.Value, node.IsReference, node.List = RedactedValue, false, nil
+			}
+		}
+	}
+	omitted := make(map[*core.Node]bool)
+	for _, query := range omit {
+		nodes, err := list.Select(query)
+		if err != nil {
+			return nil, err
+		}
+		for _, node := range nodes {
+			collectLabels(node, masked)
+			omitted[node] = true
+		}
+	}
+	if len(redact) == 0 && len(omitted) == 0 {
+		return list, nil
+	}
+	return unlabel(omitNodes(list, omitted), masked)
+}
+
+// unlabel removes the labels that are no longer referred to after the nodes
+// are replaced or removed, or returns an error if a value labeled masked is
+// still referred to.
+func unlabel(list core.List, masked map[string]bool) (core.List, error) {
+	referred := make(map[string]bool)
+	var err error
+	walk(list, func(n *core.Node) {
+		if !n.IsReference {
+			return
+		} else if masked[n.Value] && err == nil {
+			err = fmt.Errorf("marshal: ^%s refers to a redacted or omitted value", n.Value)
+		}
+		referred[n.Value] = true
+	})
+	if err != nil {
+		return nil, err
+	}
+	walk(list, func(n *core.Node) {
+		if id := n.RefLabel(); id != "" && !referred[id] {
+			n.SetRefLabel("")
+		}
+	})
+	return list, nil
+}
+
+// collectLabels records the reference labels of node and the nodes under it.
+func collectLabels(node *core.Node, labels map[string]bool) {
+	if id := node.RefLabel(); id != "" {
+		labels[id] = true
+	}
+	walk(node.List, func(n *core.Node) {
+		if id := n.RefLabel(); id != "" {
+			labels[id] = true
+		}
+	})
+}
+
+func omitNodes(list core.List, omitted map[*core.Node]bool) core.List {
+	kept := list[:0]
+	for i := range list {
+		if omitted[&list[i]] {
+			continue
+		}
+		list[i].List = omitNodes(list[i].List, omitted)
+		kept = append(kept, list[i])
+	}
+	if len(kept) == 0 {
+		return nil
+	}
+	return kept
+}
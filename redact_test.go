@@ -0,0 +1,118 @@
+package teff
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+)
+
+type testAccount struct {
+	User     string
+	Password Redacted[string]
+	Token    string   `teff:",redact"`
+	Keys     []string `teff:",redact,omitempty"`
+}
+
+func TestRedact(t *testing.T) {
+	v := testAccount{User: "a", Password: Redacted[string]{"p"}, Token: "t", Keys: []string{"k"}}
+	expected := "User:\n\ta\nPassword:\n\t<redacted>\nToken:\n\t<redacted>\nKeys:\n\t<redacted>"
+	buf, err := Marshal(&v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(buf) != expected {
+		t.Fatalf("expect\n%s\nbut got\n%s", expected, buf)
+	}
+	v.Keys = nil
+	if buf, _ = Marshal(&v); string(buf) != "User:\n\ta\nPassword:\n\t<redacted>\nToken:\n\t<redacted>" {
+		t.Fatalf("expect Keys omitted but got\n%s", buf)
+	}
+	var a testAccount
+	if err := UnmarshalStrict([]byte(expected), &a); err != nil {
+		t.Fatal(err)
+	}
+	if a.User != "a" || a.Password.Value != "" || a.Token != "" || a.Keys != nil {
+		t.Fatalf("expect redacted values decoded as zero but got %#v", a)
+	}
+	if err := Unmarshal([]byte("Password:\n\tp\nToken:\n\tt"), &a); err != nil {
+		t.Fatal(err)
+	}
+	if a.Password.Value != "p" || a.Token != "t" {
+		t.Fatalf("expect values decoded but got %#v", a)
+	}
+	if s := fmt.Sprint(a.Password); s != RedactedValue {
+		t.Fatalf("expect %s but got %s", RedactedValue, s)
+	}
+}
+
+func TestEncoderRedact(t *testing.T) {
+	type request struct {
+		ID    int
+		Path  string
+		Token string
+	}
+	v := map[string]interface{}{
+		"requests": []request{{1, "/a", "x"}, {2, "/b", "y"}},
+		"token":    "z",
+		"at":       []int{1, 2},
+	}
+	for i, testcase := range []struct {
+		redact   []string
+		omit     []string
+		expected string
+	}{
+		{nil, nil, "at:\n\t1\n\t2\nrequests:\n\t_\n\t\tID:\n\t\t\t1\n\t\tPath:\n\t\t\t/a\n\t\tToken:\n\t\t\tx\n\t_\n\t\tID:\n\t\t\t2\n\t\tPath:\n\t\t\t/b\n\t\tToken:\n\t\t\ty\ntoken:\n\tz"},
+		{[]string{"^..Token", "^token", "^at[1]"}, []string{"^requests[*]ID"}, "at:\n\t1\n\t<redacted>\nrequests:\n\t_\n\t\tPath:\n\t\t\t/a\n\t\tToken:\n\t\t\t<redacted>\n\t_\n\t\tPath:\n\t\t\t/b\n\t\tToken:\n\t\t\t<redacted>\ntoken:\n\t<redacted>"},
+		{[]string{"^requests"}, []string{"^at", "^token"}, "requests:\n\t<redacted>"},
+		{nil, []string{"^requests[*]", "^at", "^token"}, "requests:"},
+	} {
+		var buf bytes.Buffer
+		enc := NewEncoder(&buf)
+		enc.Redact(testcase.redact...)
+		enc.Omit(testcase.omit...)
+		if err := enc.Encode(v); err != nil {
+			t.Fatalf("testcase %d: %v", i, err)
+		}
+		if s := buf.String(); s != testcase.expected+"\n" {
+			t.Fatalf("testcase %d: expect\n%s\nbut got\n%s", i, testcase.expected, s)
+		}
+	}
+	enc := NewEncoder(&bytes.Buffer{})
+	enc.Redact("^[")
+	if err := enc.Encode(v); err == nil {
+		t.Fatal("expect an error of an invalid path")
+	}
+}
+
+func TestEncoderRedactReference(t *testing.T) {
+	x := 1
+	v := struct{ A, B *int }{&x, &x}
+	for i, testcase := range []struct {
+		redact   []string
+		omit     []string
+		expected string // "" if a reference would be left without its value
+	}{
+		{nil, []string{"^A"}, ""},
+		{[]string{"^A"}, nil, ""},
+		{[]string{"^A"}, []string{"^B"}, "A:\n\t<redacted>"},
+		{nil, []string{"^B"}, "A:\n\t1"},
+		{[]string{"^B"}, nil, "A:\n\t1\nB:\n\t<redacted>"},
+	} {
+		var buf bytes.Buffer
+		enc := NewEncoder(&buf)
+		enc.Redact(testcase.redact...)
+		enc.Omit(testcase.omit...)
+		err := enc.Encode(v)
+		if testcase.expected == "" {
+			if err == nil {
+				t.Fatalf("testcase %d: expect an error of a dangling reference but got\n%s", i, buf.String())
+			}
+			continue
+		} else if err != nil {
+			t.Fatalf("testcase %d: %v", i, err)
+		}
+		if s := buf.String(); s != testcase.expected+"\n" {
+			t.Fatalf("testcase %d: expect\n%s\nbut got\n%s", i, testcase.expected, s)
+		}
+	}
+}
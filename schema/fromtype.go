@@ -0,0 +1,151 @@
+package schema
+
+import (
+	"fmt"
+	"h12.io/teff"
+	"h12.io/teff/core"
+	"math"
+	"net"
+	"net/url"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+var extensionTypes = map[reflect.Type]string{
+	reflect.TypeOf(time.Time{}): Time,
+	reflect.TypeOf(net.IP{}):    IP,
+	reflect.TypeOf(url.URL{}):   URL,
+}
+
+// FromType returns the schema of the values of type t as encoded by package
+// teff. A pointer, an interface, a slice, a map or an IP is nullable, a field
+// with the option omitempty is optional, and a recursive type is described as
+// any at the point where it recurs.
+func FromType(t reflect.Type) (*Schema, error) {
+	return fromType(t, make(map[reflect.Type]bool))
+}
+
+func fromType(t reflect.Type, visiting map[reflect.Type]bool) (*Schema, error) {
+	if typ, ok := extensionTypes[t]; ok {
+		return &Schema{Type: typ, Nullable: typ == IP}, nil
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: Bool}, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		s := &Schema{Type: Int}
+		if bits := t.Bits(); bits < 64 {
+			min, max := float64(int64(-1)<<uint(bits-1)), float64(int64(1)<<uint(bits-1)-1)
+			s.Min, s.Max = &min, &max
+		}
+		return s, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		min := 0.0
+		s := &Schema{Type: Int, Min: &min}
+		if bits := t.Bits(); bits < 64 {
+			max := float64(uint64(1)<<uint(bits) - 1)
+			s.Max = &max
+		}
+		return s, nil
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: Float}, nil
+	case reflect.String:
+		return &Schema{Type: String}, nil
+	case reflect.Interface:
+		return &Schema{Type: Any, Nullable: true}, nil
+	case reflect.Ptr:
+		s, err := fromType(t.Elem(), visiting)
+		if err != nil {
+			return nil, err
+		}
+		s.Nullable = true
+		return s, nil
+	case reflect.Slice, reflect.Array:
+		elem, err := fromType(t.Elem(), visiting)
+		if err != nil {
+			return nil, err
+		}
+		return &Schema{Type: List, Elem: elem, Nullable: t.Kind() == reflect.Slice}, nil
+	case reflect.Map:
+		if t.Key().Kind() != reflect.String {
+			return nil, fmt.Errorf("schema: unsupported map key type %s", t.Key())
+		}
+		elem, err := fromType(t.Elem(), visiting)
+		if err != nil {
+			return nil, err
+		}
+		return &Schema{Type: Map, Elem: elem, Nullable: true}, nil
+	case reflect.Struct:
+		if visiting[t] {
+			return &Schema{Type: Any}, nil
+		}
+		visiting[t] = true
+		defer delete(visiting, t)
+		s := &Schema{Type: Map, Fields: []Field{}}
+		for _, f := range teff.StructFields(t) {
+			fs, err := fromType(f.Type, visiting)
+			if err != nil {
+				return nil, err
+			}
+			s.Fields = append(s.Fields, Field{Name: f.Name, Optional: f.OmitEmpty, Schema: fs})
+		}
+		return s, nil
+	}
+	return nil, fmt.Errorf("schema: unsupported type %s", t)
+}
+
+// List returns s written in the TEFF schema format.
+func (s *Schema) List() core.List {
+	return s.list(false)
+}
+
+func (s *Schema) list(optional bool) core.List {
+	var list core.List
+	if optional {
+		list = append(list, keyValue("optional", "true"))
+	}
+	list = append(list, keyValue("type", s.Type))
+	if s.Nullable {
+		list = append(list, keyValue("nullable", "true"))
+	}
+	if s.Pattern != nil {
+		list = append(list, keyValue("pattern", core.Quote(s.Pattern.String())))
+	}
+	if s.Min != nil {
+		list = append(list, keyValue("min", formatNumber(*s.Min)))
+	}
+	if s.Max != nil {
+		list = append(list, keyValue("max", formatNumber(*s.Max)))
+	}
+	if s.Elem != nil {
+		list = append(list, core.Node{Value: "elem:", List: s.Elem.list(false)})
+	}
+	if len(s.Fields) > 0 {
+		fields := core.Node{Value: "fields:"}
+		for _, f := range s.Fields {
+			fields.List = append(fields.List, core.Node{Value: fieldKey(f.Name) + ":", List: f.Schema.list(f.Optional)})
+		}
+		list = append(list, fields)
+	}
+	return list
+}
+
+func keyValue(key, value string) core.Node {
+	return core.Node{Value: key + ":", List: core.List{{Value: value}}}
+}
+
+func formatNumber(f float64) string {
+	if f == math.Trunc(f) && math.Abs(f) < 1e21 {
+		return strconv.FormatFloat(f, 'f', -1, 64)
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+// fieldKey quotes a field name that cannot be written as a raw key.
+func fieldKey(name string) string {
+	if !core.IsValue(name) || name[0] == '"' {
+		return core.Quote(name)
+	}
+	return name
+}
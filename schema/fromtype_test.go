@@ -0,0 +1,165 @@
+package schema
+
+import (
+	"bytes"
+	"h12.io/teff"
+	"h12.io/teff/core"
+	"net"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testConfig struct {
+	Name    string `teff:"name"`
+	Port    uint16
+	Hosts   []net.IP
+	Owner   *testOwner `teff:",omitempty"`
+	Labels  map[string]string
+	Next    *testConfig `teff:"next,omitempty"`
+	Ignored int         `teff:"-"`
+	private int
+}
+
+type testOwner struct {
+	Email string
+	Since time.Time
+	Home  url.URL
+}
+
+const testConfigSchema = `type:
+	map
+fields:
+	name:
+		type:
+			string
+	Port:
+		type:
+			int
+		min:
+			0
+		max:
+			65535
+	Hosts:
+		type:
+			list
+		nullable:
+			true
+		elem:
+			type:
+				ip
+			nullable:
+				true
+	Owner:
+		optional:
+			true
+		type:
+			map
+		nullable:
+			true
+		fields:
+			Email:
+				type:
+					string
+			Since:
+				type:
+					time
+			Home:
+				type:
+					url
+	Labels:
+		type:
+			map
+		nullable:
+			true
+		elem:
+			type:
+				string
+	next:
+		optional:
+			true
+		type:
+			any
+		nullable:
+			true
+`
+
+func TestFromType(t *testing.T) {
+	s, err := FromType(reflect.TypeOf(testConfig{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := s.List().Marshal(&buf, "", "\t"); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String()+"\n" != testConfigSchema {
+		t.Fatalf("expect\n%s\nbut got\n%s", testConfigSchema, buf.String())
+	}
+	parsed, err := Read(strings.NewReader(buf.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(parsed, s) {
+		t.Fatalf("expect\n%#v\nbut got\n%#v", s, parsed)
+	}
+}
+
+func TestFromTypeValidate(t *testing.T) {
+	type config struct {
+		Name  string
+		Port  int8
+		Ports []uint
+		On    bool `teff:"on,omitempty"`
+	}
+	s, err := FromType(reflect.TypeOf(config{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, testcase := range []struct {
+		v        config
+		expected []string
+	}{
+		{config{Name: "a", Port: -128, Ports: []uint{80, 443}, On: true}, nil},
+		{config{Name: "a"}, nil},
+	} {
+		data, err := teff.Marshal(testcase.v)
+		if err != nil {
+			t.Fatalf("testcase %d: %v", i, err)
+		}
+		doc, err := core.Parse(bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("testcase %d: %v", i, err)
+		}
+		if errs := Validate(doc, *s); len(errs) > 0 {
+			t.Fatalf("testcase %d: unexpected errors %v in\n%s", i, errs, data)
+		}
+	}
+	doc, err := core.Parse(strings.NewReader("Name:\n\ta\nPort:\n\t128\nPorts:\n\t-1\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "line 4: ^Port: 128 is greater than 127\nline 6: ^Ports[0]: -1 is less than 0"
+	errs := Validate(doc, *s)
+	actual := make([]string, len(errs))
+	for i, err := range errs {
+		actual[i] = err.Error()
+	}
+	if strings.Join(actual, "\n") != expected {
+		t.Fatalf("expect\n%s\nbut got\n%s", expected, strings.Join(actual, "\n"))
+	}
+}
+
+func TestFromTypeError(t *testing.T) {
+	for i, testcase := range []interface{}{
+		map[int]string{},
+		make(chan int),
+		struct{ F func() }{},
+	} {
+		if _, err := FromType(reflect.TypeOf(testcase)); err == nil {
+			t.Fatalf("testcase %d: expect error but got nil", i)
+		}
+	}
+}
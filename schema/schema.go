@@ -0,0 +1,201 @@
+// Package schema describes and validates the structure of TEFF documents.
+//
+// A schema is itself written in TEFF as a map, e.g.
+//
+//	type:
+//		map
+//	fields:
+//		name:
+//			type:
+//				string
+//			pattern:
+//				"^[a-z]+$"
+//		ports:
+//			type:
+//				list
+//			elem:
+//				type:
+//					int
+//				min:
+//					1
+//				max:
+//					65535
+//		tags:
+//			optional:
+//				true
+//			type:
+//				list
+//
+// The keys of a schema are:
+//
+//	type:     one of any, nil, bool, int, float, string, time, ip, url, list and map
+//	nullable: true if nil is also accepted
+//	optional: true if a field may be absent
+//	pattern:  a regular expression a scalar value must match
+//	min, max: the range of a numeric value
+//	elem:     the schema of the elements of a list, or the values of a map
+//	          with arbitrary keys
+//	fields:   the schemas of the fields of a map, keyed by field name
+package schema
+
+import (
+	"fmt"
+	"h12.io/teff"
+	"h12.io/teff/core"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	Any    = "any"
+	Nil    = "nil"
+	Bool   = "bool"
+	Int    = "int"
+	Float  = "float"
+	String = "string"
+	Time   = "time"
+	IP     = "ip"
+	URL    = "url"
+	List   = "list"
+	Map    = "map"
+)
+
+type Schema struct {
+	Type     string
+	Nullable bool
+	Pattern  *regexp.Regexp
+	Min, Max *float64
+	Elem     *Schema
+	Fields   []Field
+}
+
+type Field struct {
+	Name     string
+	Optional bool
+	Schema   *Schema
+}
+
+// Read reads a schema written in TEFF.
+func Read(r io.Reader) (*Schema, error) {
+	list, err := core.Parse(r)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(list)
+}
+
+// Parse parses a schema from a parsed TEFF list.
+func Parse(list core.List) (*Schema, error) {
+	s, _, err := parse(list)
+	return s, err
+}
+
+func parse(list core.List) (*Schema, bool, error) {
+	s := &Schema{Type: Any}
+	optional := false
+	for i := range list {
+		node := &list[i]
+		key, ok := keyOf(node)
+		if !ok {
+			return nil, false, errorf(node, "expect a key but got %s", node.Value)
+		}
+		if key == "elem" || key == "fields" {
+			continue
+		}
+		value, err := scalar(node)
+		if err != nil {
+			return nil, false, err
+		}
+		switch key {
+		case "type":
+			switch value {
+			case Any, Nil, Bool, Int, Float, String, Time, IP, URL, List, Map:
+				s.Type = value
+			default:
+				return nil, false, errorf(node, "unknown type %s", value)
+			}
+		case "nullable", "optional":
+			b, err := strconv.ParseBool(value)
+			if err != nil {
+				return nil, false, errorf(node, "expect a boolean but got %s", value)
+			}
+			if key == "nullable" {
+				s.Nullable = b
+			} else {
+				optional = b
+			}
+		case "pattern":
+			re, err := regexp.Compile(value)
+			if err != nil {
+				return nil, false, errorf(node, "%v", err)
+			}
+			s.Pattern = re
+		case "min", "max":
+			f, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				return nil, false, errorf(node, "expect a number but got %s", value)
+			}
+			if key == "min" {
+				s.Min = &f
+			} else {
+				s.Max = &f
+			}
+		default:
+			return nil, false, errorf(node, "unknown key %s", key)
+		}
+	}
+	for i := range list {
+		node := &list[i]
+		switch key, _ := keyOf(node); key {
+		case "elem":
+			if s.Type != List && s.Type != Map {
+				return nil, false, errorf(node, "elem is only allowed for list or map")
+			}
+			elem, _, err := parse(node.List)
+			if err != nil {
+				return nil, false, err
+			}
+			s.Elem = elem
+		case "fields":
+			if s.Type != Map {
+				return nil, false, errorf(node, "fields is only allowed for map")
+			}
+			for j := range node.List {
+				fieldNode := &node.List[j]
+				name, ok := keyOf(fieldNode)
+				if !ok {
+					return nil, false, errorf(fieldNode, "expect a field name but got %s", fieldNode.Value)
+				}
+				fs, optional, err := parse(fieldNode.List)
+				if err != nil {
+					return nil, false, err
+				}
+				s.Fields = append(s.Fields, Field{Name: name, Optional: optional, Schema: fs})
+			}
+		}
+	}
+	return s, optional, nil
+}
+
+// scalar returns the single scalar child value of a key node.
+func scalar(node *core.Node) (string, error) {
+	if len(node.List) != 1 || len(node.List[0].List) > 0 || node.List[0].IsReference {
+		return "", errorf(node, "expect a single value for %s", node.Value)
+	}
+	return teff.ParseString(node.List[0].Value)
+}
+
+// keyOf returns the key of a key node "key:".
+func keyOf(node *core.Node) (string, bool) {
+	if node.IsReference || len(node.Value) < 2 || !strings.HasSuffix(node.Value, ":") {
+		return "", false
+	}
+	key, err := teff.ParseString(strings.TrimSuffix(node.Value, ":"))
+	return key, err == nil
+}
+
+func errorf(node *core.Node, format string, args ...interface{}) error {
+	return fmt.Errorf("schema: line %d: %s", node.Line, fmt.Sprintf(format, args...))
+}
@@ -0,0 +1,168 @@
+package schema
+
+import (
+	"h12.io/teff/core"
+	"strings"
+	"testing"
+)
+
+const testSchema = `
+type:
+	map
+fields:
+	name:
+		type:
+			string
+		pattern:
+			"^[a-z]+$"
+	ports:
+		type:
+			list
+		elem:
+			type:
+				int
+			min:
+				1
+			max:
+				65535
+	owner:
+		optional:
+			true
+		nullable:
+			true
+		type:
+			map
+		fields:
+			email:
+				type:
+					string
+			since:
+				optional:
+					true
+				type:
+					time
+	labels:
+		optional:
+			true
+		type:
+			map
+		elem:
+			type:
+				string
+	hosts:
+		optional:
+			true
+		type:
+			list
+		elem:
+			type:
+				list
+			elem:
+				type:
+					ip
+`
+
+func TestValidate(t *testing.T) {
+	s, err := Read(strings.NewReader(testSchema))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, testcase := range []struct {
+		doc      string
+		expected []string
+	}{
+		{`
+name:
+	web
+ports:
+	80
+	443
+owner:
+	nil
+labels:
+	a:
+		x
+	"b c":
+		"y"
+hosts:
+	_
+		10.0.0.1
+		::1
+`, nil},
+		{`
+name:
+	"Web"
+ports:
+	0
+	x
+	70000
+owner:
+	since:
+		yesterday
+other:
+	1
+hosts:
+	_
+		10.0.0
+	1
+`, []string{
+			`line 3: ^name: "Web" does not match ^[a-z]+$`,
+			`line 5: ^ports[0]: 0 is less than 1`,
+			`line 6: ^ports[1]: expect an int but got x`,
+			`line 7: ^ports[2]: 70000 is greater than 65535`,
+			`line 10: ^owner:since: expect an RFC3339 time but got yesterday`,
+			`line 9: ^owner: missing required key email`,
+			`line 11: ^other: unknown key other`,
+			`line 15: ^hosts[0][0]: expect an IP address but got 10.0.0`,
+			`line 16: ^hosts[1]: expect _ for a list element but got 1`,
+		}},
+		{`
+ports:
+	1
+ports:
+	2
+`, []string{
+			`line 4: ^ports: duplicate key ports`,
+			`line 2: ^: missing required key name`,
+		}},
+		{`
+name:
+	a
+	b
+ports:
+	^1
+`, []string{
+			`line 3: ^name: expect a single string value but got 2 nodes`,
+		}},
+	} {
+		doc, err := core.Parse(strings.NewReader(testcase.doc))
+		if err != nil {
+			t.Fatalf("testcase %d: %v", i, err)
+		}
+		errs := Validate(doc, *s)
+		actual := make([]string, len(errs))
+		for j, err := range errs {
+			actual[j] = err.Error()
+		}
+		if strings.Join(actual, "\n") != strings.Join(testcase.expected, "\n") {
+			t.Fatalf("testcase %d: expect\n%s\nbut got\n%s", i, strings.Join(testcase.expected, "\n"), strings.Join(actual, "\n"))
+		}
+	}
+}
+
+func TestParseError(t *testing.T) {
+	for i, testcase := range []string{
+		"a",
+		"type:\n\tset",
+		"type:\n\tint\nmin:\n\tx",
+		"type:\n\tstring\npattern:\n\t\"[\"",
+		"type:\n\tint\nelem:\n\ttype:\n\t\tint",
+		"type:\n\tlist\nfields:\n\ta:\n\t\ttype:\n\t\t\tint",
+		"nullable:\n\tyes",
+		"color:\n\tred",
+	} {
+		if _, err := Read(strings.NewReader(testcase)); err == nil {
+			t.Fatalf("testcase %d: expect error but got nil", i)
+		}
+	}
+}
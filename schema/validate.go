@@ -0,0 +1,206 @@
+package schema
+
+import (
+	"fmt"
+	"h12.io/teff"
+	"h12.io/teff/core"
+	"net"
+	"net/url"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+// Error is a validation error of the node at a line and a path.
+type Error struct {
+	Line int
+	Path string
+	Msg  string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("line %d: %s: %s", e.Line, e.Path, e.Msg)
+}
+
+var (
+	intPattern   = regexp.MustCompile(`^[+-]?(0|[1-9][0-9]*)$`)
+	floatPattern = regexp.MustCompile(`^[+-]?((0|[1-9][0-9]*)\.[0-9]*([eE][+-]?(0|[1-9][0-9]*))?|(0|[1-9][0-9]*)[eE][+-]?(0|[1-9][0-9]*)|\.(0|[1-9][0-9]*)([eE][+-]?(0|[1-9][0-9]*))?)$`)
+)
+
+// Validate validates doc against s and returns all the errors found.
+func Validate(doc core.List, s Schema) []error {
+	v := &validator{}
+	v.list(doc, &s, "^", 1)
+	return v.errs
+}
+
+type validator struct {
+	errs []error
+}
+
+func (v *validator) errorf(line int, path, format string, args ...interface{}) {
+	v.errs = append(v.errs, &Error{Line: line, Path: path, Msg: fmt.Sprintf(format, args...)})
+}
+
+// list validates the value list of a key or the top level document.
+func (v *validator) list(list core.List, s *Schema, path string, line int) {
+	if len(list) > 0 {
+		line = list[0].Line
+	}
+	if s.Nullable && len(list) == 1 && isNil(&list[0]) {
+		return
+	}
+	switch s.Type {
+	case Any:
+	case List:
+		for i := range list {
+			v.elem(&list[i], s.Elem, core.JoinIndex(path, i))
+		}
+	case Map:
+		v.mapList(list, s, path, line)
+	default:
+		if len(list) != 1 {
+			v.errorf(line, path, "expect a single %s value but got %d nodes", s.Type, len(list))
+			return
+		}
+		v.scalar(&list[0], s, path)
+	}
+}
+
+// elem validates a node that is an element of a list.
+func (v *validator) elem(node *core.Node, s *Schema, path string) {
+	if s == nil || node.IsReference {
+		return
+	}
+	switch s.Type {
+	case Any:
+	case List, Map:
+		if s.Nullable && isNil(node) {
+			return
+		}
+		if node.Value != "_" {
+			v.errorf(node.Line, path, "expect _ for a %s element but got %s", s.Type, node.Value)
+			return
+		}
+		v.list(node.List, s, path, node.Line)
+	default:
+		v.scalar(node, s, path)
+	}
+}
+
+func (v *validator) mapList(list core.List, s *Schema, path string, line int) {
+	seen := make(map[string]bool)
+	for i := range list {
+		node := &list[i]
+		key, ok := keyOf(node)
+		if !ok {
+			v.errorf(node.Line, path, "expect a key but got %s", node.Value)
+			continue
+		}
+		keyPath := core.JoinKey(path, key)
+		if seen[key] {
+			v.errorf(node.Line, keyPath, "duplicate key %s", key)
+			continue
+		}
+		seen[key] = true
+		if field := s.field(key); field != nil {
+			v.list(node.List, field.Schema, keyPath, node.Line)
+		} else if len(s.Fields) == 0 && s.Elem != nil {
+			v.list(node.List, s.Elem, keyPath, node.Line)
+		} else if len(s.Fields) > 0 {
+			v.errorf(node.Line, keyPath, "unknown key %s", key)
+		}
+	}
+	for _, field := range s.Fields {
+		if !field.Optional && !seen[field.Name] {
+			v.errorf(line, path, "missing required key %s", field.Name)
+		}
+	}
+}
+
+func (s *Schema) field(name string) *Field {
+	for i := range s.Fields {
+		if s.Fields[i].Name == name {
+			return &s.Fields[i]
+		}
+	}
+	return nil
+}
+
+func (v *validator) scalar(node *core.Node, s *Schema, path string) {
+	if node.IsReference {
+		return
+	}
+	if len(node.List) > 0 {
+		v.errorf(node.Line, path, "unexpected child list of %s", node.Value)
+		return
+	}
+	if isNil(node) {
+		if !s.Nullable && s.Type != Nil {
+			v.errorf(node.Line, path, "unexpected nil for %s", s.Type)
+		}
+		return
+	}
+	value := node.Value
+	var num float64
+	isNum := false
+	switch s.Type {
+	case Nil:
+		v.errorf(node.Line, path, "expect nil but got %s", value)
+		return
+	case Bool:
+		if value != "true" && value != "false" {
+			v.errorf(node.Line, path, "expect a bool but got %s", value)
+			return
+		}
+	case Int, Float:
+		if !intPattern.MatchString(value) && (s.Type == Int || !floatPattern.MatchString(value)) {
+			v.errorf(node.Line, path, "expect %s but got %s", article(s.Type), value)
+			return
+		}
+		num, _ = strconv.ParseFloat(value, 64)
+		isNum = true
+	case String:
+		str, err := teff.ParseString(value)
+		if err != nil {
+			v.errorf(node.Line, path, "invalid string %s: %v", value, err)
+			return
+		}
+		value = str
+	case Time:
+		if _, err := time.Parse(time.RFC3339Nano, value); err != nil {
+			v.errorf(node.Line, path, "expect an RFC3339 time but got %s", value)
+			return
+		}
+	case IP:
+		if net.ParseIP(value) == nil {
+			v.errorf(node.Line, path, "expect an IP address but got %s", value)
+			return
+		}
+	case URL:
+		if u, err := url.Parse(value); err != nil || u.Scheme == "" {
+			v.errorf(node.Line, path, "expect a URL but got %s", value)
+			return
+		}
+	}
+	if s.Pattern != nil && !s.Pattern.MatchString(value) {
+		v.errorf(node.Line, path, "%s does not match %s", node.Value, s.Pattern)
+	}
+	if isNum && s.Min != nil && num < *s.Min {
+		v.errorf(node.Line, path, "%s is less than %v", node.Value, *s.Min)
+	}
+	if isNum && s.Max != nil && num > *s.Max {
+		v.errorf(node.Line, path, "%s is greater than %v", node.Value, *s.Max)
+	}
+}
+
+func isNil(node *core.Node) bool {
+	return !node.IsReference && node.Value == "nil" && len(node.List) == 0
+}
+
+func article(typ string) string {
+	if typ == Int {
+		return "an int"
+	}
+	return "a " + typ
+}
@@ -0,0 +1,218 @@
+package teff
+
+import (
+	"h12.io/teff/core"
+	"reflect"
+	"strings"
+)
+
+// encoder and decoder are the state of encoding and decoding a value that is
+// passed to the methods generated by teffgen. The generated code declares the
+// same interface types, so the methods of the state are not part of the API of
+// this package.
+type (
+	encoder = interface {
+		CollapseNil() bool
+		MarshalKey(list core.List, key string, v interface{}) (core.List, error)
+	}
+	decoder = interface {
+		Fields(list core.List, x interface{})
+		Node(node *core.Node, x interface{})
+		Scalar(list core.List, v interface{}) (*core.Node, bool)
+		Value(node *core.Node, v interface{}) bool
+		Slice(list core.List) (core.List, bool)
+		Path() string
+		Element(path string, i int, node *core.Node)
+		Error(node *core.Node, v interface{}, err error)
+		UnmarshalList(list core.List, v interface{})
+	}
+)
+
+// stateMarshaler and stateUnmarshaler are implemented by the methods generated
+// by teffgen, which encode a struct and decode the value of a key into it
+// without reflection.
+type stateMarshaler interface {
+	MarshalTEFFState(s encoder) (core.List, error)
+}
+
+type stateUnmarshaler interface {
+	UnmarshalTEFFKey(d decoder, node *core.Node) bool
+}
+
+var (
+	stateMarshalerType   = reflect.TypeOf((*stateMarshaler)(nil)).Elem()
+	stateUnmarshalerType = reflect.TypeOf((*stateUnmarshaler)(nil)).Elem()
+)
+
+// CollapseNil reports whether nil slices and maps are written as empty ones.
+func (e *encodeState) CollapseNil() bool {
+	return e.collapseNil
+}
+
+// MarshalKey appends to list the node of key with the child list encoding the
+// value pointed to by v. list must have room for the node, which is placed as
+// the encoding of the pointers encoded as its child list.
+func (e *encodeState) MarshalKey(list core.List, key string, v interface{}) (core.List, error) {
+	rv := reflect.ValueOf(v).Elem()
+	children, err := codecOf(rv.Type()).marshalList(e, rv)
+	if err != nil {
+		return nil, err
+	}
+	list = append(list, core.Node{Value: key, List: children})
+	e.place(&list[len(list)-1])
+	return list, nil
+}
+
+// Fields decodes the keys of list into x, a pointer to a struct with the
+// methods generated by teffgen, as the codec of a struct does.
+func (d *decodeState) Fields(list core.List, x interface{}) {
+	u, t := x.(stateUnmarshaler), reflect.TypeOf(x).Elem()
+	d.join()
+	path, line, label, ref := d.path, d.line, d.label, d.ref
+	defer func() { d.path, d.key, d.index, d.line, d.label, d.ref = path, nil, 0, line, label, ref }()
+	var seen map[string]bool
+	if d.strict {
+		seen = make(map[string]bool, len(list))
+	}
+	for i := range list {
+		node := &list[i]
+		if d.strict && !isKey(*node) {
+			d.path, d.key, d.index, d.line = path, nil, 0, line
+			d.errorf(node, t, "expect a key")
+			continue
+		}
+		d.path, d.key, d.index, d.line = path, node, 0, node.Line
+		if seen[node.Value] {
+			d.errorf(node, t, "duplicate key %q", strings.TrimSuffix(node.Value, ":"))
+			continue
+		}
+		d.label, d.ref = node.TypeLabel(), refOf(node)
+		if !u.UnmarshalTEFFKey(d, node) {
+			if d.strict {
+				d.errorf(node, t, "unknown key %q", strings.TrimSuffix(node.Value, ":"))
+			}
+		} else if d.strict {
+			seen[node.Value] = true
+		}
+	}
+}
+
+// join joins the key or the index of the value being decoded to the path.
+func (d *decodeState) join() {
+	if d.key != nil {
+		d.path = core.JoinKey(d.path, strings.TrimSuffix(d.key.Value, ":"))
+	} else if d.index > 0 {
+		d.path = core.JoinIndex(d.path, d.index-1)
+	}
+	d.key, d.index = nil, 0
+}
+
+// Node decodes node, an element of a list, into x as Fields does.
+func (d *decodeState) Node(node *core.Node, x interface{}) {
+	if d.strict && (node.Value != "_" || node.IsReference) {
+		d.errorf(node, reflect.TypeOf(x).Elem(), "expect _")
+		return
+	}
+	d.Fields(node.List, x)
+}
+
+// Scalar returns the node of list that the value of a basic type pointed to by
+// v is decoded from, or false if there is none.
+func (d *decodeState) Scalar(list core.List, v interface{}) (*core.Node, bool) {
+	switch {
+	case d.strict && len(list) > 1:
+		d.errorf(&list[1], reflect.TypeOf(v).Elem(), "unexpected node after a single value")
+		return nil, false
+	case len(list) != 1:
+		d.errorf(nil, reflect.TypeOf(v).Elem(), "expect a single value but got %d nodes", len(list))
+		return nil, false
+	}
+	return &list[0], d.Value(&list[0], v)
+}
+
+// Value reports whether node is a value that the value of a basic type
+// pointed to by v is decoded from.
+func (d *decodeState) Value(node *core.Node, v interface{}) bool {
+	if !d.strict {
+		return true
+	} else if node.IsReference {
+		d.errorf(node, reflect.TypeOf(v).Elem(), "unexpected reference")
+		return false
+	} else if len(node.List) > 0 {
+		d.errorf(&node.List[0], reflect.TypeOf(v).Elem(), "unexpected child")
+		return false
+	}
+	return true
+}
+
+// Slice returns the elements of a slice encoded as list, or false if it is
+// nil.
+func (d *decodeState) Slice(list core.List) (core.List, bool) {
+	if isLabeledList(list) {
+		return list[0].List, true
+	}
+	return list, !(len(list) == 1 && isNil(list[0]))
+}
+
+// Path returns the path of the value being decoded.
+func (d *decodeState) Path() string {
+	d.join()
+	return d.path
+}
+
+// Element makes node, the element i of the list at path, the value being
+// decoded.
+func (d *decodeState) Element(path string, i int, node *core.Node) {
+	d.path, d.key, d.index, d.line = path, nil, i+1, node.Line
+}
+
+// Error records err decoding node into the value pointed to by v.
+func (d *decodeState) Error(node *core.Node, v interface{}, err error) {
+	d.error(node, reflect.TypeOf(v).Elem(), err)
+}
+
+// UnmarshalList decodes list into the value pointed to by v.
+func (d *decodeState) UnmarshalList(list core.List, v interface{}) {
+	d.join()
+	rv := reflect.ValueOf(v).Elem()
+	codecOf(rv.Type()).unmarshalList(d, list, rv)
+}
+
+// withState makes the codec of a struct type t call the methods generated by
+// teffgen, which have pointer receivers, so a value that is not addressable is
+// encoded from a copy.
+func (c *codec) withState(t reflect.Type) {
+	marshalList, unmarshalList, unmarshalNode := c.marshalList, c.unmarshalList, c.unmarshalNode
+	c.marshalList = func(e *encodeState, v reflect.Value) (core.List, error) {
+		if !v.CanInterface() {
+			return marshalList(e, v)
+		} else if !v.CanAddr() {
+			p := reflect.New(t)
+			p.Elem().Set(v)
+			v = p.Elem()
+		}
+		return v.Addr().Interface().(stateMarshaler).MarshalTEFFState(e)
+	}
+	c.unmarshalList = func(d *decodeState, list core.List, v reflect.Value) {
+		if !v.CanAddr() || !v.CanInterface() {
+			unmarshalList(d, list, v)
+			return
+		}
+		d.Fields(list, v.Addr().Interface())
+	}
+	marshalList = c.marshalList
+	c.marshalNode = func(e *encodeState, v reflect.Value) (core.Node, error) {
+		list, err := marshalList(e, v)
+		if err != nil {
+			return core.Node{}, err
+		}
+		return core.Node{Value: "_", List: list}, nil
+	}
+	c.unmarshalNode = func(d *decodeState, node core.Node, v reflect.Value) {
+		if !v.CanAddr() || !v.CanInterface() {
+			unmarshalNode(d, node, v)
+			return
+		}
+		d.Node(&node, v.Addr().Interface())
+	}
+}
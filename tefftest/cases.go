@@ -0,0 +1,126 @@
+package tefftest
+
+import (
+	"errors"
+	"fmt"
+	"h12.io/teff"
+	"h12.io/teff/core"
+	"os"
+	"strings"
+	"testing"
+)
+
+var errExpectParent = errors.New("expect _")
+
+type testCase struct {
+	name     string
+	line     int
+	in, want *core.Node
+	skip     bool
+	only     bool
+}
+
+// Cases runs f as a subtest for each case of the file at path. The file is a
+// list of anonymous parents "_" with the keys "name:", "in:" and "want:", and
+// the values of in and want are decoded by teff.UnmarshalListStrict, or kept
+// as they are if the type is core.List, e.g. a want to be passed to Match. A
+// case annotated with "#<skip>" is skipped, and so are the cases not annotated
+// with "#<only>" if any case is. A failed case is reported with its line in
+// the file.
+func Cases[In, Want any](t *testing.T, path string, f func(t *testing.T, in In, want Want)) {
+	t.Helper()
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	list, err := core.Parse(file)
+	if err != nil {
+		t.Fatalf("%s: %v", path, err)
+	}
+	cases := make([]testCase, len(list))
+	only := false
+	for i := range list {
+		if err := parseCase(&list[i], &cases[i]); err != nil {
+			t.Fatalf("%s:%d: %v", path, list[i].Line, err)
+		}
+		only = only || cases[i].only
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			if c.skip || only && !c.only {
+				t.Skipf("%s:%d: skipped", path, c.line)
+			}
+			in, err := decode[In](c.in)
+			if err != nil {
+				t.Fatalf("%s: %v", path, err)
+			}
+			want, err := decode[Want](c.want)
+			if err != nil {
+				t.Fatalf("%s: %v", path, err)
+			}
+			defer func() {
+				if t.Failed() {
+					t.Logf("%s:%d: case failed", path, c.line)
+				}
+			}()
+			f(t, in, want)
+		})
+	}
+}
+
+func parseCase(node *core.Node, c *testCase) error {
+	if node.Value != "_" || node.IsReference {
+		return errExpectParent
+	}
+	c.line = node.Line
+	for _, d := range directives(node) {
+		switch d.name {
+		case "skip":
+			c.skip = true
+		case "only":
+			c.only = true
+		default:
+			return fmt.Errorf("line %d: unknown directive %s", node.Line, d)
+		}
+	}
+	seen := make(map[string]bool)
+	for i := range node.List {
+		child := &node.List[i]
+		if !child.IsKey() {
+			return fmt.Errorf("line %d: expect a key", child.Line)
+		}
+		key := strings.TrimSuffix(child.Value, ":")
+		if seen[key] {
+			return fmt.Errorf("line %d: duplicate key %q", child.Line, key)
+		}
+		seen[key] = true
+		switch key {
+		case "name":
+			if err := teff.UnmarshalListStrict(child.List, &c.name); err != nil {
+				return err
+			}
+		case "in":
+			c.in = child
+		case "want":
+			c.want = child
+		default:
+			return fmt.Errorf("line %d: unknown key %q", child.Line, key)
+		}
+	}
+	return nil
+}
+
+// decode returns the value of the key node, or the zero value if node is nil.
+func decode[T any](node *core.Node) (T, error) {
+	var v T
+	if node == nil {
+		return v, nil
+	}
+	if list, ok := any(&v).(*core.List); ok {
+		*list = node.List
+		return v, nil
+	}
+	return v, teff.UnmarshalListStrict(node.List, &v)
+}
@@ -0,0 +1,75 @@
+package tefftest
+
+import (
+	"h12.io/teff"
+	"h12.io/teff/core"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCases(t *testing.T) {
+	Cases(t, "testdata/format.teff", func(t *testing.T, in string, want string) {
+		if got := teff.FormatString(in); got != want {
+			t.Fatalf("expect %s but got %s", want, got)
+		}
+	})
+}
+
+func TestCasesMatch(t *testing.T) {
+	Cases(t, "testdata/match.teff", func(t *testing.T, in interface{}, want core.List) {
+		if err := Match(want, in); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+func TestCasesOnly(t *testing.T) {
+	var names []string
+	Cases(t, "testdata/only.teff", func(t *testing.T, in, want interface{}) {
+		names = append(names, t.Name())
+	})
+	if expected := []string{"TestCasesOnly/b"}; !reflect.DeepEqual(names, expected) {
+		t.Fatalf("expect %v but got %v", expected, names)
+	}
+}
+
+func TestParseCase(t *testing.T) {
+	for i, testcase := range []struct {
+		text string
+		err  string
+	}{
+		{"_\n\tname:\n\t\ta\n\tin:\n\t\t1\n\twant:\n\t\t2", ""},
+		{"a", "expect _"},
+		{"_\n\tx", "line 2: expect a key"},
+		{"_\n\tin:\n\tin:", `line 3: duplicate key "in"`},
+		{"_\n\tgot:", `line 2: unknown key "got"`},
+		{"#<skip>\n_\n\tname:\n\t\ta", ""},
+		{"#<skp>\n_", "line 2: unknown directive <skp>"},
+		{"_\n\tname:\n\t\ta\n\t\tb", `unmarshal: line 4: ^: cannot decode "b" into string: unexpected node after a single value`},
+	} {
+		list, err := core.Parse(strings.NewReader(testcase.text))
+		if err != nil {
+			t.Fatalf("testcase %d: %v", i, err)
+		}
+		var c testCase
+		err = parseCase(&list[0], &c)
+		if testcase.err == "" && err != nil {
+			t.Fatalf("testcase %d: %v", i, err)
+		} else if testcase.err != "" && (err == nil || err.Error() != testcase.err) {
+			t.Fatalf("testcase %d: expect error %q but got %v", i, testcase.err, err)
+		}
+	}
+}
+
+func TestDecodeCase(t *testing.T) {
+	list, err := core.Parse(strings.NewReader("in:\n\tA:\n\t\t1\n\tB:\n\t\t2"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = decode[struct{ A int }](&list[0])
+	expected := `unmarshal: line 4: ^B: cannot decode "B:" into struct { A int }: unknown key "B"`
+	if err == nil || err.Error() != expected {
+		t.Fatalf("expect error %q but got %v", expected, err)
+	}
+}
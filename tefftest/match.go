@@ -0,0 +1,264 @@
+// Package tefftest provides helpers for testing with TEFF fixtures.
+//
+// An expected document may relax the comparison of a node with a matcher
+// annotation on it:
+//
+//	#<re>           the value is a regular expression matching the whole value
+//	#<any>          any value, including its children, matches
+//	#<approx 0.001> the value is a number within the tolerance
+//	#<unordered>    the children match in any order
+//
+// A matcher on a key applies to the value of the key, i.e. <re>, <any> and
+// <approx> apply to each node of its child list that has no matcher of its
+// own, and <unordered> to the child list itself. Any other annotation in angle
+// brackets is reported as an error.
+package tefftest
+
+import (
+	"fmt"
+	"h12.io/teff"
+	"h12.io/teff/core"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// MismatchError is the first difference found between an expected and an
+// actual list.
+type MismatchError struct {
+	Line int    // line of the expected node
+	Path string // path of the actual node
+	Msg  string
+}
+
+func (e *MismatchError) Error() string {
+	return fmt.Sprintf("line %d: %s: %s", e.Line, e.Path, e.Msg)
+}
+
+// Match reports whether got, either a core.List or a value encoded by
+// teff.MarshalList, matches the expected list want, and returns a
+// *MismatchError otherwise.
+func Match(want core.List, got interface{}) error {
+	list, ok := got.(core.List)
+	if !ok {
+		var err error
+		if list, err = teff.MarshalList(got); err != nil {
+			return err
+		}
+	}
+	if err := checkMatchers("^", want); err != nil {
+		return err
+	}
+	return matchList("^", 1, want, list, matcher{})
+}
+
+type matcher struct {
+	any       bool
+	unordered bool
+	re        bool
+	approx    bool
+	tolerance float64
+}
+
+// matcherOf returns the matcher annotated on n, inheriting the value matcher
+// of its parent when n has none.
+func matcherOf(n *core.Node, parent matcher) (matcher, error) {
+	var m matcher
+	for _, d := range directives(n) {
+		switch {
+		case d.name == "any" && len(d.args) == 0:
+			m.any = true
+		case d.name == "unordered" && len(d.args) == 0:
+			m.unordered = true
+		case d.name == "re" && len(d.args) == 0:
+			m.re = true
+		case d.name == "approx" && len(d.args) == 1:
+			var err error
+			if m.tolerance, err = strconv.ParseFloat(d.args[0], 64); err != nil || m.tolerance < 0 {
+				return m, fmt.Errorf("invalid tolerance %s", d.args[0])
+			}
+			m.approx = true
+		case d.name == "any" || d.name == "unordered" || d.name == "re" || d.name == "approx":
+			return m, fmt.Errorf("invalid matcher %s", d)
+		default:
+			return m, fmt.Errorf("unknown matcher %s", d)
+		}
+	}
+	if !m.any && !m.re && !m.approx {
+		m.any, m.re, m.approx, m.tolerance = parent.any, parent.re, parent.approx, parent.tolerance
+	}
+	return m, nil
+}
+
+// checkMatchers returns the first annotation of want that is not a valid
+// matcher, so that it is reported even where a failed match is not, e.g.
+// under <unordered>.
+func checkMatchers(path string, want core.List) error {
+	for i := range want {
+		n := &want[i]
+		p := path
+		if n.IsKey() {
+			p = core.JoinKey(p, strings.TrimSuffix(n.Value, ":"))
+		} else if len(want) > 1 {
+			p = core.JoinIndex(p, i)
+		}
+		if _, err := matcherOf(n, matcher{}); err != nil {
+			return &MismatchError{n.Line, p, err.Error()}
+		}
+		if err := checkMatchers(p, n.List); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// directive is an annotation written like a type label with arguments, e.g.
+// "#<approx 0.001>".
+type directive struct {
+	name string
+	args []string
+}
+
+func (d directive) String() string {
+	return "<" + strings.Join(append([]string{d.name}, d.args...), " ") + ">"
+}
+
+// directives returns the annotations of n written as directives.
+func directives(n *core.Node) []directive {
+	var ds []directive
+	for _, a := range n.Annotations {
+		s := strings.TrimSpace(a)
+		if len(s) < 2 || s[0] != '<' || s[len(s)-1] != '>' {
+			continue
+		}
+		var d directive
+		if fields := strings.Fields(s[1 : len(s)-1]); len(fields) > 0 {
+			d.name, d.args = fields[0], fields[1:]
+		}
+		ds = append(ds, d)
+	}
+	return ds
+}
+
+func matchList(path string, line int, want, got core.List, m matcher) error {
+	if m.any {
+		return nil
+	}
+	if m.unordered {
+		return matchUnordered(path, line, want, got, m)
+	}
+	for i := range want {
+		if i == len(got) {
+			return &MismatchError{want[i].Line, path, fmt.Sprintf("expect %d nodes but got %d", len(want), len(got))}
+		}
+		p := path
+		if len(want) > 1 && !want[i].IsKey() {
+			p = core.JoinIndex(p, i)
+		}
+		if err := matchNode(p, &want[i], &got[i], m); err != nil {
+			return err
+		}
+	}
+	if len(got) > len(want) {
+		return &MismatchError{line, path, fmt.Sprintf("expect %d nodes but got %d", len(want), len(got))}
+	}
+	return nil
+}
+
+// matchUnordered pairs each node of want with a distinct node of got by
+// finding augmenting paths of a bipartite matching.
+func matchUnordered(path string, line int, want, got core.List, m matcher) error {
+	if len(want) != len(got) {
+		return &MismatchError{line, path, fmt.Sprintf("expect %d nodes but got %d", len(want), len(got))}
+	}
+	ok := make([][]bool, len(want))
+	for i := range want {
+		ok[i] = make([]bool, len(got))
+		for j := range got {
+			ok[i][j] = matchNode(path, &want[i], &got[j], m) == nil
+		}
+	}
+	owner := make([]int, len(got))
+	for j := range owner {
+		owner[j] = -1
+	}
+	var augment func(i int, seen []bool) bool
+	augment = func(i int, seen []bool) bool {
+		for j := range got {
+			if ok[i][j] && !seen[j] {
+				seen[j] = true
+				if owner[j] < 0 || augment(owner[j], seen) {
+					owner[j] = i
+					return true
+				}
+			}
+		}
+		return false
+	}
+	for i := range want {
+		if !augment(i, make([]bool, len(got))) {
+			return &MismatchError{want[i].Line, path, fmt.Sprintf("no match for %s", want[i].Value)}
+		}
+	}
+	return nil
+}
+
+func matchNode(path string, want, got *core.Node, parent matcher) error {
+	m, err := matcherOf(want, parent)
+	if err != nil {
+		return &MismatchError{want.Line, path, err.Error()}
+	}
+	if want.IsKey() {
+		if got.Value != want.Value || got.IsReference {
+			return mismatch(path, want, got)
+		}
+		key := strings.TrimSuffix(want.Value, ":")
+		return matchList(core.JoinKey(path, key), want.Line, want.List, got.List, m)
+	}
+	if m.any {
+		return nil
+	}
+	if want.Value == "_" && !want.IsReference {
+		if got.Value != "_" || got.IsReference {
+			return mismatch(path, want, got)
+		}
+		return matchList(path, want.Line, want.List, got.List, m)
+	}
+	if got.IsReference != want.IsReference || got.IsKey() || got.Value == "_" {
+		return mismatch(path, want, got)
+	}
+	switch {
+	case m.re:
+		re, err := regexp.Compile(`^(?:` + value(want.Value) + `)$`)
+		if err != nil {
+			return &MismatchError{want.Line, path, err.Error()}
+		} else if !re.MatchString(value(got.Value)) {
+			return mismatch(path, want, got)
+		}
+	case m.approx:
+		w, err1 := strconv.ParseFloat(value(want.Value), 64)
+		g, err2 := strconv.ParseFloat(value(got.Value), 64)
+		if err1 != nil || err2 != nil || math.Abs(w-g) > m.tolerance {
+			return mismatch(path, want, got)
+		}
+	default:
+		if got.Value != want.Value {
+			return mismatch(path, want, got)
+		}
+	}
+	return matchList(path, want.Line, want.List, got.List, matcher{unordered: m.unordered})
+}
+
+func mismatch(path string, want, got *core.Node) error {
+	return &MismatchError{want.Line, path, fmt.Sprintf("expect %s but got %s", want.Value, got.Value)}
+}
+
+// value returns the string represented by a value, or the value itself if it
+// is not a valid string.
+func value(v string) string {
+	if s, err := teff.ParseString(v); err == nil {
+		return s
+	}
+	return v
+}
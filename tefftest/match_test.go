@@ -0,0 +1,83 @@
+package tefftest
+
+import (
+	"h12.io/teff/core"
+	"strings"
+	"testing"
+)
+
+type testUser struct {
+	ID      string
+	Name    string
+	Score   float64
+	Tags    []string
+	Created string
+}
+
+func TestMatch(t *testing.T) {
+	got := testUser{
+		ID:      "7c9e6679-7425-40de-944b-e07fc1f90ae7",
+		Name:    "alice",
+		Score:   0.30000000000000004,
+		Tags:    []string{"b", "a", "c"},
+		Created: "2015-01-02T03:04:05.123Z",
+	}
+	for i, testcase := range []struct {
+		want string
+		err  string
+	}{
+		{"ID:\n\t7c9e6679-7425-40de-944b-e07fc1f90ae7\nName:\n\talice\nScore:\n\t0.30000000000000004\nTags:\n\tb\n\ta\n\tc\nCreated:\n\t2015-01-02T03:04:05.123Z", ""},
+		{"#<re>\nID:\n\t[0-9a-f]{8}(-[0-9a-f]{4}){3}-[0-9a-f]{12}\nName:\n\talice\n#<approx 1e-9>\nScore:\n\t0.3\n#<unordered>\nTags:\n\ta\n\tb\n\tc\n#<any>\nCreated:", ""},
+		{"ID:\n\t#<any>\n\tx\nName:\n\t#<re>\n\tal.*\nScore:\n\t#<approx 0.01>\n\t0.3\nTags:\n\t#<any>\n\t_\n\ta\n\tc\nCreated:\n\t#<re>\n\t2015-.*", ""},
+		{"#<any>\nID:\nName:\n\tbob", "line 4: ^Name: expect bob but got alice"},
+		{"#<any>\nID:\nName:\n\t#<re>\n\tal", "line 5: ^Name: expect al but got alice"},
+		{"#<any>\nID:\nName:\n\talice\n#<approx 0.01>\nScore:\n\t0.4", "line 7: ^Score: expect 0.4 but got 0.30000000000000004"},
+		{"#<any>\nID:\nName:\n\talice\nScore:\n\t0.3", "line 6: ^Score: expect 0.3 but got 0.30000000000000004"},
+		{"#<any>\nID:\nName:\n\talice\n#<any>\nScore:\nTags:\n\ta\n\tb\n\tc", "line 8: ^Tags[0]: expect a but got b"},
+		{"#<any>\nID:\nName:\n\talice\n#<any>\nScore:\n#<unordered>\nTags:\n\ta\n\tb\n\tb", "line 11: ^Tags: no match for b"},
+		{"#<any>\nID:\nName:\n\talice\n#<any>\nScore:\n#<unordered>\nTags:\n\ta\n\tb", "line 8: ^Tags: expect 2 nodes but got 3"},
+		{"#<any>\nID:", "line 1: ^: expect 1 nodes but got 5"},
+		{"#<any>\nID:\nName:\n\t#<re>\n\t(", "line 5: ^Name: error parsing regexp: missing closing ): `^(?:()$`"},
+		{"#<any>\nID:\n#<approx>\nName:\n\talice", "line 4: ^Name: invalid matcher <approx>"},
+		{"#<any>\nID:\nName:\n\talice\n#<aprox 0.1>\nScore:\n\t0.3", "line 6: ^Score: unknown matcher <aprox 0.1>"},
+		{"#<any>\nID:\nName:\n\talice\n#<any>\nScore:\n#<unordered>\nTags:\n\ta\n\t#<>\n\tb\n\tc", "line 11: ^Tags[1]: unknown matcher <>"},
+		{"#<any>\nID:\n#<approx x>\nName:", "line 4: ^Name: invalid tolerance x"},
+	} {
+		want, err := core.Parse(strings.NewReader(testcase.want))
+		if err != nil {
+			t.Fatalf("testcase %d: %v", i, err)
+		}
+		err = Match(want, got)
+		if testcase.err == "" && err != nil {
+			t.Fatalf("testcase %d: %v", i, err)
+		} else if testcase.err != "" && (err == nil || err.Error() != testcase.err) {
+			t.Fatalf("testcase %d: expect error %q but got %v", i, testcase.err, err)
+		}
+	}
+}
+
+func TestMatchUnordered(t *testing.T) {
+	for i, testcase := range []struct {
+		want string
+		got  string
+		ok   bool
+	}{
+		{"#<unordered>\n_\n\t1\n\t2", "_\n\t2\n\t1", true},
+		{"#<unordered>\n_\n\t#<re>\n\t.*\n\t1", "_\n\t1\n\t2", true},
+		{"#<unordered>\n_\n\t_\n\t\tx:\n\t\t\t1\n\t_\n\t\tx:\n\t\t\t2", "_\n\t_\n\t\tx:\n\t\t\t2\n\t_\n\t\tx:\n\t\t\t1", true},
+		{"#<unordered>\n_\n\t1\n\t1", "_\n\t1\n\t2", false},
+		{"_\n\t1\n\t2", "_\n\t2\n\t1", false},
+	} {
+		want, err := core.Parse(strings.NewReader(testcase.want))
+		if err != nil {
+			t.Fatalf("testcase %d: %v", i, err)
+		}
+		got, err := core.Parse(strings.NewReader(testcase.got))
+		if err != nil {
+			t.Fatalf("testcase %d: %v", i, err)
+		}
+		if err := Match(want, got); (err == nil) != testcase.ok {
+			t.Fatalf("testcase %d: expect match %v but got %v", i, testcase.ok, err)
+		}
+	}
+}
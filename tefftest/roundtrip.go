@@ -0,0 +1,516 @@
+package tefftest
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"h12.io/teff"
+	"math"
+	"math/rand"
+	"net"
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+var (
+	errDiffer = errors.New("decoded value differs")
+	errShared = errors.New("decoded pointers are shared differently")
+)
+
+// maxShrinks is the maximum number of smaller values tried when shrinking.
+const maxShrinks = 10000
+
+// RoundTripOptions configures the values generated by RoundTrip. A zero
+// field takes its default value.
+type RoundTripOptions struct {
+	N         int     // number of values, 100 by default
+	Seed      int64   // seed of the values, random by default
+	MaxDepth  int     // nesting depth of pointers, slices, maps and structs, 3 by default
+	MaxLen    int     // maximum length of strings, slices and maps, 4 by default
+	NilRate   float64 // probability of a nil pointer, slice, map or interface
+	ShareRate float64 // probability of a pointer sharing a value generated before, possibly cyclic
+	Unicode   bool    // whether strings contain any code point rather than ASCII
+}
+
+// RoundTrip checks that random values of T are decoded unchanged by
+// teff.UnmarshalStrict after being written by teff.Encoder, and reports the
+// first value that is not, shrunk to a minimal example. Unexported fields and
+// fields tagged with redact are left zero, and so are interfaces with methods,
+// as they are not decoded. Pointers sharing a value, including cycles, must be
+// decoded sharing a value alike.
+func RoundTrip[T any](t testing.TB, opts RoundTripOptions) {
+	t.Helper()
+	if opts.N == 0 {
+		opts.N = 100
+	}
+	if opts.MaxDepth == 0 {
+		opts.MaxDepth = 3
+	}
+	if opts.MaxLen == 0 {
+		opts.MaxLen = 4
+	}
+	if opts.Seed == 0 {
+		opts.Seed = time.Now().UnixNano()
+	}
+	typ := reflect.TypeOf((*T)(nil)).Elem()
+	g := &generator{rand: rand.New(rand.NewSource(opts.Seed)), opts: opts}
+	for i := 0; i < opts.N; i++ {
+		g.ptrs = make(map[reflect.Type][]reflect.Value)
+		v := g.value(typ, 0)
+		if err := roundTrip(v); err != nil {
+			v, err = shrink(v, err)
+			t.Fatalf("round trip of %s with seed %d: %v\n%s", typ, opts.Seed, err, report(v))
+			return
+		}
+	}
+}
+
+// roundTrip encodes and decodes v and compares the result with v.
+func roundTrip(v reflect.Value) error {
+	p := reflect.New(v.Type())
+	p.Elem().Set(v)
+	var buf bytes.Buffer
+	if err := teff.NewEncoder(&buf).Encode(p.Interface()); err != nil {
+		return err
+	}
+	decoded := reflect.New(v.Type())
+	if err := teff.UnmarshalStrict(buf.Bytes(), decoded.Interface()); err != nil {
+		return err
+	}
+	if !reflect.DeepEqual(p.Interface(), decoded.Interface()) {
+		return errDiffer
+	} else if !shared(p, decoded, make(map[pointer]uintptr), make(map[pointer]uintptr)) {
+		return errShared
+	}
+	return nil
+}
+
+// pointer identifies a pointer by its type as well as its address.
+type pointer struct {
+	addr uintptr
+	typ  reflect.Type
+}
+
+// shared reports whether two deeply equal values a and b share their
+// pointers alike, i.e. two pointers of a are equal if and only if those at
+// the same places of b are. ab and ba map the pointers of a and b visited to
+// each other. Pointers to zero-size values are not compared.
+func shared(a, b reflect.Value, ab, ba map[pointer]uintptr) bool {
+	switch a.Kind() {
+	case reflect.Ptr:
+		if a.IsNil() || a.Type().Elem().Size() == 0 {
+			return true
+		}
+		pa, pb := pointer{a.Pointer(), a.Type()}, pointer{b.Pointer(), b.Type()}
+		qb, okA := ab[pa]
+		qa, okB := ba[pb]
+		if okA || okB {
+			return okA && okB && qb == pb.addr && qa == pa.addr
+		}
+		ab[pa], ba[pb] = pb.addr, pa.addr
+		return shared(a.Elem(), b.Elem(), ab, ba)
+	case reflect.Interface:
+		return a.IsNil() || shared(a.Elem(), b.Elem(), ab, ba)
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < a.Len(); i++ {
+			if !shared(a.Index(i), b.Index(i), ab, ba) {
+				return false
+			}
+		}
+	case reflect.Map:
+		iter := a.MapRange()
+		for iter.Next() {
+			if !shared(iter.Value(), b.MapIndex(iter.Key()), ab, ba) {
+				return false
+			}
+		}
+	case reflect.Struct:
+		for i := 0; i < a.NumField(); i++ {
+			if !shared(a.Field(i), b.Field(i), ab, ba) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// report returns the text of v and of the value decoded from it.
+func report(v reflect.Value) string {
+	p := reflect.New(v.Type())
+	p.Elem().Set(v)
+	var buf bytes.Buffer
+	if err := teff.NewEncoder(&buf).Encode(p.Interface()); err != nil {
+		return fmt.Sprintf("value:\n%#v", v.Interface())
+	}
+	s := "value:\n" + buf.String()
+	decoded := reflect.New(v.Type())
+	if err := teff.UnmarshalStrict(buf.Bytes(), decoded.Interface()); err == nil {
+		buf.Reset()
+		if err := teff.NewEncoder(&buf).Encode(decoded.Interface()); err == nil {
+			s += "decoded:\n" + buf.String()
+		}
+	}
+	return s
+}
+
+// shrink returns the smallest value found that still fails the round trip,
+// by replacing v with the first smaller candidate that fails until none does.
+func shrink(v reflect.Value, err error) (reflect.Value, error) {
+	tries := 0
+	for shrunk := true; shrunk && tries < maxShrinks; {
+		shrunk = false
+		candidates(v, make(map[uintptr]bool), func(c reflect.Value) bool {
+			if tries++; tries > maxShrinks {
+				return true
+			}
+			if cerr := roundTrip(c); cerr != nil {
+				v, err, shrunk = c, cerr, true
+				return true
+			}
+			return false
+		})
+	}
+	return v, err
+}
+
+// candidates calls try with values smaller than v until it returns true, and
+// reports whether it did.
+func candidates(v reflect.Value, seen map[uintptr]bool, try func(c reflect.Value) bool) bool {
+	t := v.Type()
+	if !v.IsZero() && try(reflect.Zero(t)) {
+		return true
+	}
+	with := func(f func(c reflect.Value)) reflect.Value {
+		c := reflect.New(t).Elem()
+		c.Set(v)
+		f(c)
+		return c
+	}
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if i := v.Int(); i/2 != 0 && try(with(func(c reflect.Value) { c.SetInt(i / 2) })) {
+			return true
+		} else if i > 0 && try(with(func(c reflect.Value) { c.SetInt(i - 1) })) {
+			return true
+		} else if i < 0 && try(with(func(c reflect.Value) { c.SetInt(i + 1) })) {
+			return true
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if u := v.Uint(); u/2 != 0 && try(with(func(c reflect.Value) { c.SetUint(u / 2) })) {
+			return true
+		} else if u > 0 && try(with(func(c reflect.Value) { c.SetUint(u - 1) })) {
+			return true
+		}
+	case reflect.Float32, reflect.Float64:
+		if f := v.Float(); math.Trunc(f) != f && try(with(func(c reflect.Value) { c.SetFloat(math.Trunc(f)) })) {
+			return true
+		}
+	case reflect.String:
+		s := v.String()
+		for _, sub := range shorter(s) {
+			sub := sub
+			if try(with(func(c reflect.Value) { c.SetString(sub) })) {
+				return true
+			}
+		}
+	case reflect.Slice:
+		if v.IsNil() {
+			return false
+		}
+		for i := 0; i < v.Len(); i++ {
+			c := reflect.MakeSlice(t, 0, v.Len()-1)
+			c = reflect.AppendSlice(c, v.Slice(0, i))
+			c = reflect.AppendSlice(c, v.Slice(i+1, v.Len()))
+			if try(c) {
+				return true
+			}
+		}
+		for i := 0; i < v.Len(); i++ {
+			if candidates(v.Index(i), seen, func(e reflect.Value) bool {
+				c := reflect.MakeSlice(t, v.Len(), v.Len())
+				reflect.Copy(c, v)
+				c.Index(i).Set(e)
+				return try(c)
+			}) {
+				return true
+			}
+		}
+	case reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			if candidates(v.Index(i), seen, func(e reflect.Value) bool {
+				return try(with(func(c reflect.Value) { c.Index(i).Set(e) }))
+			}) {
+				return true
+			}
+		}
+	case reflect.Map:
+		if v.IsNil() {
+			return false
+		}
+		for _, k := range v.MapKeys() {
+			c := copyMap(v)
+			c.SetMapIndex(k, reflect.Value{})
+			if try(c) {
+				return true
+			}
+		}
+		for _, k := range v.MapKeys() {
+			if candidates(v.MapIndex(k), seen, func(e reflect.Value) bool {
+				c := copyMap(v)
+				c.SetMapIndex(k, e)
+				return try(c)
+			}) {
+				return true
+			}
+		}
+	case reflect.Ptr:
+		if v.IsNil() || seen[v.Pointer()] {
+			return false
+		}
+		seen[v.Pointer()] = true
+		defer delete(seen, v.Pointer())
+		return candidates(v.Elem(), seen, func(e reflect.Value) bool {
+			c := reflect.New(t.Elem())
+			c.Elem().Set(e)
+			return try(c)
+		})
+	case reflect.Interface:
+		if v.IsNil() {
+			return false
+		}
+		return candidates(v.Elem(), seen, func(e reflect.Value) bool {
+			return try(with(func(c reflect.Value) { c.Set(e) }))
+		})
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if !t.Field(i).IsExported() {
+				continue
+			}
+			if candidates(v.Field(i), seen, func(e reflect.Value) bool {
+				return try(with(func(c reflect.Value) { c.Field(i).Set(e) }))
+			}) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// shorter returns the strings made from s by removing a half or a character,
+// or replacing a character with "a".
+func shorter(s string) []string {
+	var ss []string
+	if n := len(s) / 2; n > 0 {
+		for n < len(s) && !utf8.RuneStart(s[n]) {
+			n++
+		}
+		ss = append(ss, s[:n], s[n:])
+	}
+	for i, r := range s {
+		ss = append(ss, s[:i]+s[i+utf8.RuneLen(r):])
+	}
+	for i, r := range s {
+		if r != 'a' {
+			ss = append(ss, s[:i]+"a"+s[i+utf8.RuneLen(r):])
+		}
+	}
+	return ss
+}
+
+func copyMap(v reflect.Value) reflect.Value {
+	c := reflect.MakeMapWithSize(v.Type(), v.Len())
+	iter := v.MapRange()
+	for iter.Next() {
+		c.SetMapIndex(iter.Key(), iter.Value())
+	}
+	return c
+}
+
+var (
+	timeType = reflect.TypeOf(time.Time{})
+	ipType   = reflect.TypeOf(net.IP{})
+	urlType  = reflect.TypeOf(url.URL{})
+)
+
+// trickyStrings are strings that could be read as something else if written
+// as they are.
+var trickyStrings = []string{"", "nil", "_", "true", "1", "-1.5", "1+2i", "a:", " ", `"`, "#", "^", "2015-01-02T03:04:05Z", "::1"}
+
+// generator generates random values.
+type generator struct {
+	rand *rand.Rand
+	opts RoundTripOptions
+	ptrs map[reflect.Type][]reflect.Value // pointers generated before by type
+}
+
+func (g *generator) chance(rate float64) bool {
+	return rate > 0 && g.rand.Float64() < rate
+}
+
+func (g *generator) value(t reflect.Type, depth int) reflect.Value {
+	v := reflect.New(t).Elem()
+	switch t {
+	case timeType:
+		v.Set(reflect.ValueOf(time.Unix(g.rand.Int63n(1<<33), g.rand.Int63n(1e9)).UTC()))
+		return v
+	case ipType:
+		if g.rand.Intn(4) == 0 {
+			return v
+		}
+		ip := make(net.IP, net.IPv6len)
+		g.rand.Read(ip)
+		if g.rand.Intn(2) == 0 {
+			ip = net.IPv4(ip[0], ip[1], ip[2], ip[3])
+		}
+		v.Set(reflect.ValueOf(ip))
+		return v
+	case urlType:
+		if g.rand.Intn(4) == 0 {
+			return v
+		}
+		u, _ := url.Parse(fmt.Sprintf("https://example.com/%d?q=%d", g.rand.Intn(100), g.rand.Intn(100)))
+		v.Set(reflect.ValueOf(u).Elem())
+		return v
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		v.SetBool(g.rand.Intn(2) == 0)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		v.SetInt(g.rand.Int63() >> uint(g.rand.Intn(64)) >> uint(64-t.Bits()))
+		if g.rand.Intn(2) == 0 {
+			v.SetInt(-v.Int())
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		v.SetUint(g.rand.Uint64() >> uint(g.rand.Intn(64)) >> uint(64-t.Bits()))
+	case reflect.Float32, reflect.Float64:
+		v.SetFloat(g.float(t.Bits()))
+	case reflect.Complex64, reflect.Complex128:
+		v.SetComplex(complex(g.float(t.Bits()/2), g.float(t.Bits()/2)))
+	case reflect.String:
+		v.SetString(g.string())
+	case reflect.Slice:
+		if g.chance(g.opts.NilRate) {
+			return v
+		}
+		n := g.len(depth)
+		v.Set(reflect.MakeSlice(t, n, n))
+		for i := 0; i < n; i++ {
+			v.Index(i).Set(g.value(t.Elem(), depth+1))
+		}
+	case reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			v.Index(i).Set(g.value(t.Elem(), depth+1))
+		}
+	case reflect.Map:
+		if g.chance(g.opts.NilRate) {
+			return v
+		}
+		n := g.len(depth)
+		v.Set(reflect.MakeMapWithSize(t, n))
+		for i := 0; i < n; i++ {
+			v.SetMapIndex(g.value(t.Key(), depth+1), g.value(t.Elem(), depth+1))
+		}
+	case reflect.Ptr:
+		if ptrs := g.ptrs[t]; len(ptrs) > 0 && g.chance(g.opts.ShareRate) {
+			return ptrs[g.rand.Intn(len(ptrs))]
+		}
+		if depth >= g.opts.MaxDepth || g.chance(g.opts.NilRate) {
+			return v
+		}
+		v.Set(reflect.New(t.Elem()))
+		g.ptrs[t] = append(g.ptrs[t], v)
+		v.Elem().Set(g.value(t.Elem(), depth+1))
+	case reflect.Interface:
+		if t.NumMethod() > 0 || g.chance(g.opts.NilRate) {
+			return v
+		}
+		if x := g.any(depth); x != nil {
+			v.Set(reflect.ValueOf(x))
+		}
+	case reflect.Struct:
+		for _, f := range teff.StructFields(t) {
+			if !f.Redact {
+				v.FieldByIndex(f.Index).Set(g.value(f.Type, depth+1))
+			}
+		}
+	}
+	return v
+}
+
+// any returns a value of a type decoded into an empty interface.
+func (g *generator) any(depth int) interface{} {
+	n := 7
+	if depth >= g.opts.MaxDepth {
+		n = 5
+	}
+	switch g.rand.Intn(n) {
+	case 0:
+		return g.string()
+	case 1:
+		return g.rand.Int63() - g.rand.Int63()
+	case 2:
+		return g.float(64)
+	case 3:
+		return g.rand.Intn(2) == 0
+	case 4:
+		return complex(g.float(64), g.float(64))
+	case 5:
+		s := make([]interface{}, g.len(depth))
+		for i := range s {
+			s[i] = g.any(depth + 1)
+		}
+		return s
+	}
+	m := make(map[string]interface{})
+	for i := g.len(depth); i > 0; i-- {
+		m[g.string()] = g.any(depth + 1)
+	}
+	return m
+}
+
+func (g *generator) len(depth int) int {
+	if depth >= g.opts.MaxDepth {
+		return 0
+	}
+	return g.rand.Intn(g.opts.MaxLen + 1)
+}
+
+// float returns a finite float of bits, either a small one or one with random
+// bits.
+func (g *generator) float(bits int) float64 {
+	for {
+		var f float64
+		switch {
+		case g.rand.Intn(2) == 0:
+			f = float64(g.rand.Intn(2001)-1000) / 8
+		case bits == 32:
+			f = float64(math.Float32frombits(g.rand.Uint32()))
+		default:
+			f = math.Float64frombits(g.rand.Uint64())
+		}
+		if !math.IsNaN(f) && !math.IsInf(f, 0) {
+			return f
+		}
+	}
+}
+
+func (g *generator) string() string {
+	if g.rand.Intn(4) == 0 {
+		return trickyStrings[g.rand.Intn(len(trickyStrings))]
+	}
+	const ascii = "ab01 _:#^\"\\.-\t\n"
+	rs := make([]rune, g.rand.Intn(g.opts.MaxLen+1))
+	for i := range rs {
+		if !g.opts.Unicode || g.rand.Intn(2) == 0 {
+			rs[i] = rune(ascii[g.rand.Intn(len(ascii))])
+			continue
+		}
+		for rs[i] = rune(g.rand.Intn(utf8.MaxRune + 1)); !utf8.ValidRune(rs[i]); {
+			rs[i] = rune(g.rand.Intn(utf8.MaxRune + 1))
+		}
+	}
+	return string(rs)
+}
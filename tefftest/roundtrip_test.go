@@ -0,0 +1,79 @@
+package tefftest
+
+import (
+	"fmt"
+	"h12.io/teff"
+	"h12.io/teff/core"
+	"net"
+	"net/url"
+	"testing"
+	"time"
+)
+
+type testNode struct {
+	Name     string
+	Score    float64
+	Count    int8
+	Flags    []bool
+	Labels   map[string]uint
+	Attrs    map[int]interface{}
+	Pair     [2]*int
+	At       time.Time
+	Addr     net.IP
+	Home     url.URL
+	Children []*testNode
+	Next     *testNode
+}
+
+type testLoop struct {
+	Next *testLoop
+}
+
+// testTruncated loses all but the first 3 bytes when decoded.
+type testTruncated string
+
+func (s *testTruncated) UnmarshalTEFF(list core.List) error {
+	var v string
+	err := teff.UnmarshalList(list, &v)
+	if len(v) > 3 {
+		v = v[:3]
+	}
+	*s = testTruncated(v)
+	return err
+}
+
+type recorder struct {
+	testing.TB
+	msg string
+}
+
+func (r *recorder) Helper() {}
+
+func (r *recorder) Fatalf(format string, args ...interface{}) {
+	r.msg = fmt.Sprintf(format, args...)
+}
+
+func TestRoundTrip(t *testing.T) {
+	for seed := int64(1); seed <= 10; seed++ {
+		RoundTrip[testNode](t, RoundTripOptions{Seed: seed, NilRate: 0.2, ShareRate: 0.2, Unicode: true})
+		RoundTrip[interface{}](t, RoundTripOptions{Seed: seed, MaxDepth: 4, Unicode: true})
+		RoundTrip[map[float32][]*string](t, RoundTripOptions{Seed: seed, NilRate: 0.5})
+	}
+}
+
+func TestRoundTripShrink(t *testing.T) {
+	r := &recorder{TB: t}
+	RoundTrip[[]testTruncated](r, RoundTripOptions{Seed: 1, MaxLen: 8})
+	expected := "round trip of []tefftest.testTruncated with seed 1: decoded value differs\nvalue:\naaaa\ndecoded:\naaa\n"
+	if r.msg != expected {
+		t.Fatalf("expect\n%s\nbut got\n%s", expected, r.msg)
+	}
+}
+
+func TestRoundTripCycle(t *testing.T) {
+	r := &recorder{TB: t}
+	RoundTrip[testLoop](r, RoundTripOptions{Seed: 1, ShareRate: 1})
+	if r.msg != "" {
+		t.Fatalf("expect a cycle decoded but got\n%s", r.msg)
+	}
+}
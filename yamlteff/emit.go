@@ -0,0 +1,129 @@
+package yamlteff
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+)
+
+const yamlIndent = "  "
+
+func (n *node) writeYAML(w *bytes.Buffer) {
+	switch {
+	case n.kind == mapKind && len(n.pairs) > 0:
+		n.writeAnchor(w, "", "\n")
+		n.writeMap(w, "")
+	case n.kind == seqKind && len(n.items) > 0:
+		n.writeAnchor(w, "", "\n")
+		n.writeSeq(w, "")
+	default:
+		writeComments(w, n.comments, "")
+		n.writeAnchor(w, "", " ")
+		w.WriteString(n.inline())
+		w.WriteByte('\n')
+	}
+}
+
+func (n *node) writeMap(w *bytes.Buffer, indent string) {
+	for _, p := range n.pairs {
+		writeComments(w, p.comments, indent)
+		w.WriteString(indent)
+		w.WriteString(yamlString(p.key, true))
+		w.WriteByte(':')
+		p.value.writeValue(w, indent)
+	}
+}
+
+func (n *node) writeSeq(w *bytes.Buffer, indent string) {
+	for _, item := range n.items {
+		writeComments(w, item.comments, indent)
+		w.WriteString(indent)
+		w.WriteByte('-')
+		if item.kind == mapKind && item.anchor == "" && len(item.pairs) > 0 && len(item.pairs[0].comments) == 0 {
+			// compact form, e.g. "- a: 1"
+			var buf bytes.Buffer
+			item.writeMap(&buf, indent+yamlIndent)
+			w.WriteByte(' ')
+			w.Write(buf.Bytes()[len(indent+yamlIndent):])
+			continue
+		}
+		item.writeValue(w, indent)
+	}
+}
+
+// writeValue writes n after "key:" or "-" and ends the line.
+func (n *node) writeValue(w *bytes.Buffer, indent string) {
+	switch {
+	case n.kind == mapKind && len(n.pairs) > 0:
+		n.writeAnchor(w, " ", "")
+		w.WriteByte('\n')
+		n.writeMap(w, indent+yamlIndent)
+	case n.kind == seqKind && len(n.items) > 0:
+		n.writeAnchor(w, " ", "")
+		w.WriteByte('\n')
+		n.writeSeq(w, indent+yamlIndent)
+	default:
+		w.WriteByte(' ')
+		n.writeAnchor(w, "", " ")
+		w.WriteString(n.inline())
+		w.WriteByte('\n')
+	}
+}
+
+func (n *node) writeAnchor(w *bytes.Buffer, before, after string) {
+	if n.anchor != "" {
+		w.WriteString(before + "&" + n.anchor + after)
+	}
+}
+
+// inline returns n written on a single line.
+func (n *node) inline() string {
+	switch n.kind {
+	case aliasKind:
+		return "*" + n.value
+	case mapKind:
+		return "{}"
+	case seqKind:
+		return "[]"
+	}
+	switch n.typ {
+	case nullType:
+		return "null"
+	case boolType, numType:
+		return n.value
+	}
+	return yamlString(n.value, false)
+}
+
+func writeComments(w *bytes.Buffer, comments []string, indent string) {
+	for _, c := range comments {
+		w.WriteString(indent)
+		w.WriteByte('#')
+		w.WriteString(c)
+		w.WriteByte('\n')
+	}
+}
+
+// yamlString returns s as a plain scalar if it is read back as the same
+// string, otherwise as a double quoted scalar.
+func yamlString(s string, isKey bool) string {
+	if isPlainSafe(s) && (isKey || resolve(s) == strType) {
+		return s
+	}
+	return strconv.Quote(s)
+}
+
+func isPlainSafe(s string) bool {
+	if s == "" || strings.TrimSpace(s) != s || strings.ContainsAny(s[:1], "-?:,[]{}#&*!|>'\"%@`") {
+		return false
+	}
+	if strings.Contains(s, ": ") || strings.Contains(s, " #") || strings.HasSuffix(s, ":") {
+		return false
+	}
+	for _, r := range s {
+		if r < ' ' || r == 0x7f || r == 0xfeff || r == 0x85 || r == 0x2028 || r == 0x2029 {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,653 @@
+package yamlteff
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+type kind int
+
+const (
+	scalarKind kind = iota
+	mapKind
+	seqKind
+	aliasKind
+)
+
+type scalarType int
+
+const (
+	strType scalarType = iota
+	nullType
+	boolType
+	numType
+)
+
+// node is a YAML node. A scalar keeps its text in value, already unquoted
+// for a quoted scalar, and an alias keeps the anchor name in value.
+type node struct {
+	kind     kind
+	typ      scalarType
+	value    string
+	anchor   string
+	comments []string
+	pairs    []pair
+	items    []*node
+	line     int
+}
+
+type pair struct {
+	key      string
+	comments []string
+	value    *node
+}
+
+type line struct {
+	indent int
+	text   string
+	num    int
+}
+
+// parser parses the block style subset of YAML used by most fixtures:
+// block mappings and sequences, flow collections on a single line, plain,
+// single and double quoted scalars on a single line, literal and folded block
+// scalars, anchors, aliases and comments. Only the scalar tags of the core
+// schema are resolved, other tags are ignored, and only a single document is
+// accepted.
+type parser struct {
+	lines    []line
+	pos      int
+	comments []string
+}
+
+func parseYAML(src string) (*node, error) {
+	p := &parser{}
+	if err := p.split(src); err != nil {
+		return nil, err
+	}
+	p.skip()
+	if p.cur() == nil {
+		return &node{typ: nullType}, nil
+	}
+	n, err := p.parseBlock()
+	if err != nil {
+		return nil, err
+	}
+	p.skip()
+	if l := p.cur(); l != nil {
+		return nil, p.errorf(l, "unexpected content %q", l.text)
+	}
+	return n, nil
+}
+
+func (p *parser) split(src string) error {
+	src = strings.TrimPrefix(src, "\ufeff")
+	started := false
+	for i, s := range strings.Split(src, "\n") {
+		s = strings.TrimSuffix(s, "\r")
+		text := strings.TrimLeft(s, " ")
+		l := line{indent: len(s) - len(text), text: text, num: i + 1}
+		if strings.HasPrefix(text, "\t") && strings.TrimSpace(text) != "" {
+			return p.errorf(&l, "tab in indentation")
+		}
+		switch {
+		case l.indent == 0 && strings.HasPrefix(text, "%"):
+			continue
+		case l.indent == 0 && (text == "---" || strings.HasPrefix(text, "--- ")):
+			if started {
+				return p.errorf(&l, "multiple documents are not supported")
+			}
+			started = true
+			if rest := strings.TrimSpace(text[3:]); rest != "" && !strings.HasPrefix(rest, "#") {
+				l.text = rest
+				p.lines = append(p.lines, l)
+			}
+			continue
+		case l.indent == 0 && text == "...":
+			return nil
+		}
+		started = started || !isBlankOrComment(text)
+		p.lines = append(p.lines, l)
+	}
+	return nil
+}
+
+func (p *parser) cur() *line {
+	if p.pos < len(p.lines) {
+		return &p.lines[p.pos]
+	}
+	return nil
+}
+
+// skip skips blank and comment lines, collecting the comments.
+func (p *parser) skip() {
+	for ; p.pos < len(p.lines); p.pos++ {
+		text := strings.TrimRight(p.lines[p.pos].text, " \t")
+		switch {
+		case text == "":
+		case strings.HasPrefix(text, "#"):
+			p.comments = append(p.comments, text[1:])
+		default:
+			return
+		}
+	}
+}
+
+func (p *parser) takeComments() []string {
+	cs := p.comments
+	p.comments = nil
+	return cs
+}
+
+func (p *parser) errorf(l *line, format string, args ...interface{}) error {
+	return fmt.Errorf("yamlteff: line %d: %s", l.num, fmt.Sprintf(format, args...))
+}
+
+// parseBlock parses the node starting at the current line.
+func (p *parser) parseBlock() (*node, error) {
+	l := p.cur()
+	if isSeqItem(l.text) {
+		return p.parseSeq(l.indent)
+	}
+	if _, _, ok, err := splitKey(l.text); err != nil {
+		return nil, p.errorf(l, "%v", err)
+	} else if ok {
+		return p.parseMap(l.indent)
+	}
+	return p.parseValue(l.text, l.indent-1)
+}
+
+func (p *parser) parseSeq(indent int) (*node, error) {
+	seq := &node{kind: seqKind, items: []*node{}, line: p.cur().num}
+	for {
+		p.skip()
+		l := p.cur()
+		if l == nil || l.indent < indent || (l.indent == indent && !isSeqItem(l.text)) {
+			return seq, nil
+		}
+		if l.indent > indent {
+			return nil, p.errorf(l, "bad indentation of a sequence entry")
+		}
+		comments := p.takeComments()
+		rest := strings.TrimLeft(l.text[1:], " ")
+		var item *node
+		var err error
+		if isSeqItem(rest) || isCompactKey(rest) {
+			// compact nested collection, e.g. "- a: 1" or "- - a"
+			*l = line{indent: l.indent + len(l.text) - len(rest), text: rest, num: l.num}
+			item, err = p.parseBlock()
+		} else {
+			item, err = p.parseValue(rest, indent)
+		}
+		if err != nil {
+			return nil, err
+		}
+		item.comments = append(comments, item.comments...)
+		seq.items = append(seq.items, item)
+	}
+}
+
+func (p *parser) parseMap(indent int) (*node, error) {
+	m := &node{kind: mapKind, pairs: []pair{}, line: p.cur().num}
+	for {
+		p.skip()
+		l := p.cur()
+		if l == nil || l.indent < indent {
+			return m, nil
+		}
+		if l.indent > indent {
+			return nil, p.errorf(l, "bad indentation of a mapping entry")
+		}
+		key, rest, ok, err := splitKey(l.text)
+		if err != nil {
+			return nil, p.errorf(l, "%v", err)
+		}
+		if !ok {
+			return nil, p.errorf(l, "expect a mapping key but got %q", l.text)
+		}
+		comments := p.takeComments()
+		value, err := p.parseMapValue(rest, indent)
+		if err != nil {
+			return nil, err
+		}
+		comments = append(comments, value.comments...)
+		value.comments = nil
+		m.pairs = append(m.pairs, pair{key, comments, value})
+	}
+}
+
+func (p *parser) parseMapValue(rest string, indent int) (*node, error) {
+	// a sequence may start at the same indent as its key
+	l := p.cur()
+	anchor, tag, body, comment := properties(rest)
+	if body == "" {
+		next := p.pos + 1
+		for next < len(p.lines) && isBlankOrComment(p.lines[next].text) {
+			next++
+		}
+		if next < len(p.lines) && p.lines[next].indent == indent && isSeqItem(p.lines[next].text) {
+			p.pos++
+			p.skip()
+			n, err := p.parseSeq(indent)
+			if err != nil {
+				return nil, err
+			}
+			if err := n.setTag(tag); err != nil {
+				return nil, p.errorf(l, "%v", err)
+			}
+			n.anchor = anchor
+			n.comments = appendComment(n.comments, comment)
+			return n, nil
+		}
+	}
+	return p.parseValue(rest, indent)
+}
+
+// parseValue parses the value text following "- " or "key:" on the current
+// line, whose parent is at indent, together with any child block.
+func (p *parser) parseValue(rest string, indent int) (*node, error) {
+	l := p.cur()
+	anchor, tag, body, comment := properties(rest)
+	var n *node
+	var err error
+	switch {
+	case body == "":
+		p.pos++
+		p.skip()
+		if next := p.cur(); next != nil && next.indent > indent {
+			n, err = p.parseBlock()
+			if err != nil {
+				return nil, err
+			}
+		} else {
+			n = &node{typ: nullType, line: l.num}
+		}
+	case body[0] == '|' || body[0] == '>':
+		p.pos++
+		n, err = p.parseBlockScalar(body, indent, l)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		var c string
+		n, c, err = parseInline(body)
+		if err != nil {
+			return nil, p.errorf(l, "%v", err)
+		}
+		comment = c
+		n.line = l.num
+		p.pos++
+	}
+	if err := n.setTag(tag); err != nil {
+		return nil, p.errorf(l, "%v", err)
+	}
+	if anchor != "" {
+		n.anchor = anchor
+	}
+	n.comments = appendComment(n.comments, comment)
+	return n, nil
+}
+
+func (p *parser) parseBlockScalar(header string, indent int, l *line) (*node, error) {
+	style := header[0]
+	chomp := byte(0)
+	for _, c := range []byte(strings.TrimSpace(strings.SplitN(header[1:], "#", 2)[0])) {
+		switch c {
+		case '-', '+':
+			chomp = c
+		default:
+			return nil, p.errorf(l, "unsupported block scalar header %q", header)
+		}
+	}
+	var lines []string
+	contentIndent := -1
+	for ; p.pos < len(p.lines); p.pos++ {
+		cl := p.lines[p.pos]
+		if strings.TrimSpace(cl.text) == "" {
+			lines = append(lines, "")
+			continue
+		}
+		if cl.indent <= indent {
+			break
+		}
+		if contentIndent < 0 {
+			contentIndent = cl.indent
+		}
+		if cl.indent < contentIndent {
+			return nil, p.errorf(&cl, "bad indentation of a block scalar")
+		}
+		lines = append(lines, strings.Repeat(" ", cl.indent-contentIndent)+cl.text)
+	}
+	// trailing blank lines are only kept by "+" chomping
+	trailing := 0
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+		trailing++
+	}
+	var s string
+	if style == '|' {
+		s = strings.Join(lines, "\n")
+	} else {
+		for i, line := range lines {
+			switch {
+			case i == 0:
+			case line == "":
+				s += "\n"
+				continue
+			case lines[i-1] == "":
+			case strings.HasPrefix(line, " ") || strings.HasPrefix(lines[i-1], " "):
+				s += "\n"
+			default:
+				s += " "
+			}
+			s += line
+		}
+	}
+	switch {
+	case chomp == '+':
+		s += strings.Repeat("\n", trailing+1)
+	case chomp == 0 && len(lines) > 0:
+		s += "\n"
+	}
+	return &node{value: s, line: l.num}, nil
+}
+
+// properties splits the anchor, the tag, the body and the trailing comment of
+// a value.
+func properties(s string) (anchor, tag, body, comment string) {
+	for {
+		s = strings.TrimLeft(s, " ")
+		switch {
+		case strings.HasPrefix(s, "&"):
+			end := strings.IndexAny(s, " \t")
+			if end < 0 {
+				end = len(s)
+			}
+			anchor, s = s[1:end], s[end:]
+			continue
+		case strings.HasPrefix(s, "!"):
+			end := strings.IndexAny(s, " \t")
+			if end < 0 {
+				end = len(s)
+			}
+			tag, s = s[:end], s[end:]
+			continue
+		case strings.HasPrefix(s, "#"):
+			return anchor, tag, "", s[1:]
+		}
+		return anchor, tag, strings.TrimRight(s, " \t"), ""
+	}
+}
+
+func appendComment(cs []string, c string) []string {
+	if c == "" {
+		return cs
+	}
+	return append(cs, c)
+}
+
+func isBlankOrComment(text string) bool {
+	text = strings.TrimSpace(text)
+	return text == "" || strings.HasPrefix(text, "#")
+}
+
+func isSeqItem(text string) bool {
+	return text == "-" || strings.HasPrefix(text, "- ")
+}
+
+func isCompactKey(text string) bool {
+	_, _, ok, _ := splitKey(text)
+	return ok
+}
+
+// splitKey splits a mapping entry "key: rest" into its key and rest.
+func splitKey(text string) (key, rest string, ok bool, err error) {
+	if text == "" {
+		return "", "", false, nil
+	}
+	end := -1
+	switch text[0] {
+	case '"', '\'':
+		n, c, err := parseQuoted(text)
+		if err != nil {
+			return "", "", false, err
+		}
+		key, end = n, c
+		if end >= len(text) || text[end] != ':' {
+			return "", "", false, nil
+		}
+	case '[', '{', '#', '&', '*', '!', '|', '>':
+		return "", "", false, nil
+	default:
+		for i := 0; i < len(text); i++ {
+			if text[i] == '#' && i > 0 && text[i-1] == ' ' {
+				return "", "", false, nil
+			}
+			if text[i] == ':' && (i+1 == len(text) || text[i+1] == ' ') {
+				end = i
+				break
+			}
+		}
+		if end <= 0 {
+			return "", "", false, nil
+		}
+		key = strings.TrimRight(text[:end], " ")
+	}
+	rest = text[end+1:]
+	if rest != "" && rest[0] != ' ' {
+		return "", "", false, nil
+	}
+	return key, strings.TrimLeft(rest, " "), true, nil
+}
+
+// parseInline parses a single line value: an alias, a quoted or plain scalar
+// or a flow collection, and returns the trailing comment.
+func parseInline(s string) (*node, string, error) {
+	var n *node
+	var end int
+	var err error
+	switch s[0] {
+	case '*':
+		end = strings.IndexAny(s, " \t")
+		if end < 0 {
+			end = len(s)
+		}
+		n = &node{kind: aliasKind, value: s[1:end]}
+	case '"', '\'':
+		var v string
+		v, end, err = parseQuoted(s)
+		n = &node{value: v}
+	case '[', '{':
+		n, end, err = parseFlow(s, 0)
+	default:
+		end = len(s)
+		if i := strings.Index(s, " #"); i >= 0 {
+			end = i
+		}
+		v := strings.TrimRight(s[:end], " \t")
+		n = &node{value: v, typ: resolve(v)}
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	rest := strings.TrimLeft(s[end:], " \t")
+	switch {
+	case rest == "":
+		return n, "", nil
+	case strings.HasPrefix(rest, "#"):
+		return n, rest[1:], nil
+	}
+	return nil, "", fmt.Errorf("unexpected %q after value", rest)
+}
+
+// parseFlow parses a flow collection or scalar starting at s[i].
+func parseFlow(s string, i int) (*node, int, error) {
+	i = skipSpaces(s, i)
+	if i >= len(s) {
+		return nil, i, fmt.Errorf("unexpected end of flow collection")
+	}
+	switch s[i] {
+	case '[':
+		n := &node{kind: seqKind, items: []*node{}}
+		i = skipSpaces(s, i+1)
+		for i < len(s) && s[i] != ']' {
+			item, j, err := parseFlow(s, i)
+			if err != nil {
+				return nil, j, err
+			}
+			n.items = append(n.items, item)
+			if i = skipSpaces(s, j); i < len(s) && s[i] == ',' {
+				i = skipSpaces(s, i+1)
+			}
+		}
+		if i >= len(s) {
+			return nil, i, fmt.Errorf("unterminated flow sequence")
+		}
+		return n, i + 1, nil
+	case '{':
+		n := &node{kind: mapKind, pairs: []pair{}}
+		i = skipSpaces(s, i+1)
+		for i < len(s) && s[i] != '}' {
+			key, j, err := parseFlow(s, i)
+			if err != nil {
+				return nil, j, err
+			}
+			if key.kind != scalarKind {
+				return nil, j, fmt.Errorf("unsupported flow mapping key")
+			}
+			if i = skipSpaces(s, j); i >= len(s) || s[i] != ':' {
+				return nil, i, fmt.Errorf("expect : in flow mapping")
+			}
+			value, j, err := parseFlow(s, i+1)
+			if err != nil {
+				return nil, j, err
+			}
+			n.pairs = append(n.pairs, pair{key: key.value, value: value})
+			if i = skipSpaces(s, j); i < len(s) && s[i] == ',' {
+				i = skipSpaces(s, i+1)
+			}
+		}
+		if i >= len(s) {
+			return nil, i, fmt.Errorf("unterminated flow mapping")
+		}
+		return n, i + 1, nil
+	case '"', '\'':
+		v, end, err := parseQuoted(s[i:])
+		return &node{value: v}, i + end, err
+	case '*':
+		end := i + 1
+		for end < len(s) && !strings.ContainsRune(" ,]}", rune(s[end])) {
+			end++
+		}
+		return &node{kind: aliasKind, value: s[i+1 : end]}, end, nil
+	}
+	end := i
+	for end < len(s) && !strings.ContainsRune(",[]{}", rune(s[end])) &&
+		!(s[end] == ':' && (end+1 == len(s) || strings.ContainsRune(" ,]}", rune(s[end+1])))) {
+		end++
+	}
+	v := strings.TrimRight(s[i:end], " ")
+	return &node{value: v, typ: resolve(v)}, end, nil
+}
+
+func skipSpaces(s string, i int) int {
+	for i < len(s) && s[i] == ' ' {
+		i++
+	}
+	return i
+}
+
+// parseQuoted parses the single or double quoted scalar at the start of s
+// and returns its value and the position after the closing quote.
+func parseQuoted(s string) (string, int, error) {
+	q := s[0]
+	var b strings.Builder
+	for i := 1; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == q && q == '\'' && i+1 < len(s) && s[i+1] == '\'':
+			b.WriteByte('\'')
+			i++
+		case c == q:
+			return b.String(), i + 1, nil
+		case c == '\\' && q == '"':
+			if i+1 >= len(s) {
+				return "", 0, fmt.Errorf("unterminated escape")
+			}
+			i++
+			if r, ok := simpleEscapes[s[i]]; ok {
+				b.WriteString(r)
+				continue
+			}
+			size := map[byte]int{'x': 2, 'u': 4, 'U': 8}[s[i]]
+			if size == 0 || i+size >= len(s) {
+				return "", 0, fmt.Errorf("invalid escape \\%c", s[i])
+			}
+			code, err := strconv.ParseUint(s[i+1:i+1+size], 16, 32)
+			if err != nil {
+				return "", 0, fmt.Errorf("invalid escape \\%s", s[i:i+1+size])
+			}
+			b.WriteRune(rune(code))
+			i += size
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return "", 0, fmt.Errorf("unterminated quoted scalar")
+}
+
+var simpleEscapes = map[byte]string{
+	'0': "\x00", 'a': "\a", 'b': "\b", 't': "\t", '\t': "\t", 'n': "\n",
+	'v': "\v", 'f': "\f", 'r': "\r", 'e': "\x1b", ' ': " ", '"': "\"",
+	'/': "/", '\\': "\\", 'N': "\u0085", '_': "\u00a0", 'L': "\u2028",
+	'P': "\u2029",
+}
+
+var (
+	nullPattern  = regexp.MustCompile(`^(~|null|Null|NULL)?$`)
+	boolPattern  = regexp.MustCompile(`^(true|True|TRUE|false|False|FALSE)$`)
+	intPattern   = regexp.MustCompile(`^([-+]?[0-9]+|0o[0-7]+|0x[0-9a-fA-F]+)$`)
+	floatPattern = regexp.MustCompile(`^([-+]?(\.[0-9]+|[0-9]+(\.[0-9]*)?)([eE][-+]?[0-9]+)?|[-+]?\.(inf|Inf|INF)|\.(nan|NaN|NAN))$`)
+)
+
+// setTag sets the type of a scalar by a tag of the core schema, e.g. "!!str",
+// and returns an error if the value is not of the type. Other tags are
+// ignored.
+func (n *node) setTag(tag string) error {
+	typ, pattern := strType, (*regexp.Regexp)(nil)
+	switch tag {
+	case "!!str":
+	case "!!null":
+		typ, pattern = nullType, nullPattern
+	case "!!bool":
+		typ, pattern = boolType, boolPattern
+	case "!!int":
+		typ, pattern = numType, intPattern
+	case "!!float":
+		typ, pattern = numType, floatPattern
+	default:
+		return nil
+	}
+	if n.kind != scalarKind {
+		return fmt.Errorf("unexpected tag %s", tag)
+	} else if pattern != nil && !pattern.MatchString(n.value) && !(tag == "!!float" && intPattern.MatchString(n.value)) {
+		return fmt.Errorf("invalid %s value %q", tag, n.value)
+	}
+	n.typ = typ
+	return nil
+}
+
+// resolve resolves the type of a plain scalar by the YAML 1.2 core schema.
+func resolve(s string) scalarType {
+	switch {
+	case nullPattern.MatchString(s):
+		return nullType
+	case boolPattern.MatchString(s):
+		return boolType
+	case intPattern.MatchString(s), floatPattern.MatchString(s):
+		return numType
+	}
+	return strType
+}
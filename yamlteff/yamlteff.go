@@ -0,0 +1,265 @@
+// Package yamlteff converts between YAML documents and TEFF lists.
+//
+// Mappings, sequences and scalars are encoded the same way as package
+// jsonteff does: a mapping as a list of key nodes "key:", a sequence as a
+// list of its elements with an anonymous parent "_" for a nested collection,
+// and a collection whose type cannot be told from its children labeled with
+// "<object>" or "<array>".
+//
+// An anchor becomes a reference label "# ^anchor" and an alias a reference
+// "^anchor". The label of a scalar is put on the scalar node, and the label
+// of a collection on its parent, i.e. the key node or the "_" node. Comments
+// become comment annotations of the following mapping entry or sequence
+// element. Integers in hexadecimal or octal are converted to decimals, and
+// infinities and NaNs are converted to strings.
+//
+// The YAML parser is built in, so that no external dependency is needed. It
+// supports the subset of YAML that fixtures are usually written in: block
+// mappings and sequences, single line flow collections, plain and quoted
+// scalars, literal and folded block scalars, anchors, aliases and comments.
+// A scalar tagged !!str, !!int, !!float, !!bool or !!null is converted as a
+// value of the tag, and other tags are ignored.
+package yamlteff
+
+import (
+	"bytes"
+	"fmt"
+	"h12.io/teff"
+	"h12.io/teff/core"
+	"io"
+	"io/ioutil"
+	"math/big"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	anonymous   = "_"
+	objectLabel = "object"
+	arrayLabel  = "array"
+)
+
+// FromYAML reads a YAML document and converts it to a list.
+func FromYAML(r io.Reader) (core.List, error) {
+	src, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	n, err := parseYAML(string(src))
+	if err != nil {
+		return nil, err
+	}
+	list, label := n.list()
+	if n.kind != scalarKind && n.kind != aliasKind && (label != "" || n.anchor != "") {
+		node := core.Node{Value: anonymous, List: list}
+		node.SetTypeLabel(label)
+		node.SetRefLabel(n.anchor)
+		return core.List{node}, nil
+	}
+	return list, nil
+}
+
+// ToYAML converts list to YAML and writes it to w.
+func ToYAML(w io.Writer, list core.List) error {
+	var n *node
+	var err error
+	if len(list) == 1 && list[0].Value == anonymous && (list[0].TypeLabel() != "" || list[0].RefLabel() != "") {
+		n, err = fromNode(&list[0])
+	} else {
+		n, err = fromList(list, "", "")
+	}
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	n.writeYAML(&buf)
+	_, err = w.Write(buf.Bytes())
+	return err
+}
+
+// list returns the child list encoding n, and the type label its parent
+// needs when the type of n cannot be told from the list.
+func (n *node) list() (core.List, string) {
+	switch n.kind {
+	case mapKind:
+		list := make(core.List, len(n.pairs))
+		for i, p := range n.pairs {
+			list[i] = core.Node{Value: teff.FormatString(p.key) + ":", Annotations: p.comments}
+			var label string
+			list[i].List, label = p.value.list()
+			list[i].SetTypeLabel(label)
+			if p.value.kind == mapKind || p.value.kind == seqKind {
+				list[i].SetRefLabel(p.value.anchor)
+			}
+		}
+		if len(list) == 0 {
+			return list, objectLabel
+		}
+		return list, ""
+	case seqKind:
+		list := make(core.List, len(n.items))
+		for i, item := range n.items {
+			if item.kind == scalarKind || item.kind == aliasKind {
+				list[i] = item.scalarNode()
+				continue
+			}
+			list[i] = core.Node{Value: anonymous, Annotations: item.comments}
+			var label string
+			list[i].List, label = item.list()
+			list[i].SetTypeLabel(label)
+			list[i].SetRefLabel(item.anchor)
+		}
+		if len(list) <= 1 {
+			return list, arrayLabel
+		}
+		return list, ""
+	}
+	return core.List{n.scalarNode()}, ""
+}
+
+func (n *node) scalarNode() core.Node {
+	if n.kind == aliasKind {
+		return core.Node{Value: n.value, IsReference: true, Annotations: n.comments}
+	}
+	node := core.Node{Value: n.teffValue(), Annotations: n.comments}
+	node.SetRefLabel(n.anchor)
+	return node
+}
+
+func (n *node) teffValue() string {
+	switch n.typ {
+	case nullType:
+		return "nil"
+	case boolType:
+		return strings.ToLower(n.value)
+	case numType:
+		if isNumber(n.value) {
+			return n.value
+		}
+		if i, ok := parseInt(n.value); ok {
+			return i.String()
+		}
+		if f, err := strconv.ParseFloat(n.value, 64); err == nil && !isSpecialFloat(n.value) {
+			return strconv.FormatFloat(f, 'g', -1, 64)
+		}
+	}
+	return teff.FormatUntyped(n.value)
+}
+
+func parseInt(s string) (*big.Int, bool) {
+	base := 10
+	switch {
+	case strings.HasPrefix(s, "0x"):
+		s, base = s[2:], 16
+	case strings.HasPrefix(s, "0o"):
+		s, base = s[2:], 8
+	}
+	return new(big.Int).SetString(s, base)
+}
+
+func isSpecialFloat(s string) bool {
+	return strings.Contains(strings.ToLower(s), "inf") || strings.Contains(strings.ToLower(s), "nan")
+}
+
+var numberPattern = regexp.MustCompile(`^[+-]?((0|[1-9][0-9]*)(\.[0-9]*)?([eE][+-]?[0-9]+)?|\.[0-9]+([eE][+-]?[0-9]+)?)$`)
+
+// isNumber reports whether s is a TEFF integer or float.
+func isNumber(s string) bool {
+	return numberPattern.MatchString(s)
+}
+
+func fromList(list core.List, label, anchor string) (*node, error) {
+	var n *node
+	switch {
+	case label == objectLabel || (label == "" && len(list) > 0 && isKey(&list[0])):
+		n = &node{kind: mapKind, pairs: []pair{}}
+		for i := range list {
+			keyNode := &list[i]
+			if !isKey(keyNode) {
+				return nil, fmt.Errorf("yamlteff: line %d: expect a key but got %s", keyNode.Line, keyNode.Value)
+			}
+			key, err := teff.ParseString(strings.TrimSuffix(keyNode.Value, ":"))
+			if err != nil {
+				return nil, fmt.Errorf("yamlteff: line %d: %v", keyNode.Line, err)
+			}
+			value, err := fromList(keyNode.List, keyNode.TypeLabel(), keyNode.RefLabel())
+			if err != nil {
+				return nil, err
+			}
+			comments := append(keyNode.Comments(), value.comments...)
+			value.comments = nil
+			n.pairs = append(n.pairs, pair{key, comments, value})
+		}
+	case label == "" && len(list) == 0:
+		n = &node{typ: nullType}
+	case label == arrayLabel || (label == "" && len(list) > 1):
+		n = &node{kind: seqKind, items: []*node{}}
+		for i := range list {
+			item, err := fromNode(&list[i])
+			if err != nil {
+				return nil, err
+			}
+			n.items = append(n.items, item)
+		}
+	case label == "":
+		item, err := fromNode(&list[0])
+		if err != nil {
+			return nil, err
+		}
+		n = item
+	default:
+		return nil, fmt.Errorf("yamlteff: unknown type label <%s>", label)
+	}
+	if anchor != "" {
+		n.anchor = anchor
+	}
+	return n, nil
+}
+
+func fromNode(tn *core.Node) (*node, error) {
+	if tn.IsReference {
+		return &node{kind: aliasKind, value: tn.Value, comments: tn.Comments()}, nil
+	}
+	if isKey(tn) {
+		return nil, fmt.Errorf("yamlteff: line %d: unexpected key %s", tn.Line, tn.Value)
+	}
+	if tn.Value == anonymous {
+		label := tn.TypeLabel()
+		if label == "" {
+			label = arrayLabel
+			if len(tn.List) > 0 && isKey(&tn.List[0]) {
+				label = objectLabel
+			}
+		}
+		n, err := fromList(tn.List, label, tn.RefLabel())
+		if err != nil {
+			return nil, err
+		}
+		n.comments = tn.Comments()
+		return n, nil
+	}
+	if len(tn.List) > 0 {
+		return nil, fmt.Errorf("yamlteff: line %d: unexpected child list of %s", tn.Line, tn.Value)
+	}
+	n := &node{anchor: tn.RefLabel(), comments: tn.Comments()}
+	switch {
+	case tn.Value == "nil":
+		n.typ = nullType
+	case tn.Value == "true" || tn.Value == "false":
+		n.typ, n.value = boolType, tn.Value
+	case isNumber(tn.Value):
+		n.typ, n.value = numType, tn.Value
+	default:
+		s, err := teff.ParseString(tn.Value)
+		if err != nil {
+			return nil, fmt.Errorf("yamlteff: line %d: %v", tn.Line, err)
+		}
+		n.value = s
+	}
+	return n, nil
+}
+
+func isKey(n *core.Node) bool {
+	return !n.IsReference && len(n.Value) > 1 && strings.HasSuffix(n.Value, ":")
+}
@@ -0,0 +1,107 @@
+package yamlteff
+
+import (
+	"bytes"
+	"h12.io/teff/core"
+	"strings"
+	"testing"
+)
+
+func TestConvert(t *testing.T) {
+	for i, testcase := range []struct {
+		yaml string
+		teff string
+	}{
+		{"1\n", "1"},
+		{"null\n", "nil"},
+		{"a b\n", "a b"},
+		{"\"1\"\n", `"1"`},
+		{"- 1\n- \"true\"\n- null\n", "1\n\"true\"\nnil"},
+		{"[]\n", "#<array>\n_"},
+		{"{}\n", "#<object>\n_"},
+		{"- 5\n", "#<array>\n_\n\t5"},
+		{
+			"name: x\nports:\n  - 80\n  - 443\nempty: []\nmeta: {}\n",
+			"name:\n\tx\nports:\n\t80\n\t443\n#<array>\nempty:\n#<object>\nmeta:",
+		},
+		{
+			"- a: 1\n  b: 2\n-\n  - x\n  - y\n",
+			"_\n\ta:\n\t\t1\n\tb:\n\t\t2\n_\n\tx\n\ty",
+		},
+		{
+			"# server\nbase: &base\n  host: h\n  port: 1\ncopy: *base\nname: &n x\nalias: *n\n",
+			"# server\n# ^base\nbase:\n\thost:\n\t\th\n\tport:\n\t\t1\ncopy:\n\t^base\nname:\n\t# ^n\n\tx\nalias:\n\t^n",
+		},
+		{
+			"list:\n  # first\n  - &a 1\n  - &b\n    k: v\n  - *b\n",
+			"list:\n\t# first\n\t# ^a\n\t1\n\t# ^b\n\t_\n\t\tk:\n\t\t\tv\n\t^b",
+		},
+		{
+			"\"a: b\": \" x\"\n\"#\": ^\ns: \"line\\nbreak\"\n",
+			"a: b:\n\t\" x\"\n\"#\":\n\t\"^\"\ns:\n\t\"line\\nbreak\"",
+		},
+	} {
+		list, err := FromYAML(strings.NewReader(testcase.yaml))
+		if err != nil {
+			t.Fatalf("testcase %d: %v", i, err)
+		}
+		if s := list.String(); s != testcase.teff {
+			t.Fatalf("testcase %d: expect \n%s\nbut got \n%s", i, testcase.teff, s)
+		}
+		parsed, err := core.Parse(strings.NewReader(testcase.teff))
+		if err != nil {
+			t.Fatalf("testcase %d: %v", i, err)
+		}
+		var buf bytes.Buffer
+		if err := ToYAML(&buf, parsed); err != nil {
+			t.Fatalf("testcase %d: %v", i, err)
+		}
+		if buf.String() != testcase.yaml {
+			t.Fatalf("testcase %d: expect \n%s\nbut got \n%s", i, testcase.yaml, buf.String())
+		}
+	}
+}
+
+func TestFromYAML(t *testing.T) {
+	for i, testcase := range []struct {
+		yaml string
+		teff string
+	}{
+		{"---\na: 1 # one\n...\nignored", "# one\na:\n\t1"},
+		{"a:\n- 1\n- 2\nb: ~", "a:\n\t1\n\t2\nb:\n\tnil"},
+		{"a: [1, \"x\", [y], {k: v}]", "a:\n\t1\n\tx\n\t#<array>\n\t_\n\t\ty\n\t_\n\t\tk:\n\t\t\tv"},
+		{"a: 0x1F\nb: 007\nc: .inf\nd: True\ne: 'it''s'", "a:\n\t31\nb:\n\t7\nc:\n\t.inf\nd:\n\ttrue\ne:\n\tit's"},
+		{"a: |\n  x\n  y\nb: >-\n  x\n  y\n\n  z\n", "a:\n\t\"x\\ny\\n\"\nb:\n\t\"x y\\nz\""},
+		{"a: !!str 1", "a:\n\t\"1\""},
+		{"a: !!str\nb: !!str true\nc: &x !!str ~", "a:\n\t\"\"\nb:\n\t\"true\"\nc:\n\t# ^x\n\t~"},
+		{"a: !!int '0x1F'\nb: !!float \"1.5\"\nc: !!bool 'True'\nd: !!null ''", "a:\n\t31\nb:\n\t1.5\nc:\n\ttrue\nd:\n\tnil"},
+		{"a: !custom 1", "a:\n\t1"},
+	} {
+		list, err := FromYAML(strings.NewReader(testcase.yaml))
+		if err != nil {
+			t.Fatalf("testcase %d: %v", i, err)
+		}
+		if s := list.String(); s != testcase.teff {
+			t.Fatalf("testcase %d: expect \n%s\nbut got \n%s", i, testcase.teff, s)
+		}
+	}
+}
+
+func TestFromYAMLError(t *testing.T) {
+	for i, testcase := range []string{
+		"a: 1\n b: 2",
+		"- 1\n  - 2",
+		"a: 1\n- 2",
+		"a: \"x",
+		"a: [1, 2",
+		"\ta: 1",
+		"a: 1\n---\nb: 2",
+		"a: !!int x",
+		"a: !!bool 1",
+		"a: !!str\n  b: 1",
+	} {
+		if _, err := FromYAML(strings.NewReader(testcase)); err == nil {
+			t.Fatalf("testcase %d: expect error but got nil", i)
+		}
+	}
+}